@@ -0,0 +1,203 @@
+package calculator
+
+import (
+	"math/big"
+	"strings"
+)
+
+// DefaultPrecision is the precision of EvalBigFloat in bits, when the Calculator is not created WithPrecision.
+// 256 bits are about 77 decimal digits
+const DefaultPrecision uint = 256
+
+// WithPrecision sets the precision of EvalBigFloat in bits. 333 bits are about 100 decimal digits.
+// Panics if the precision is 0
+func WithPrecision(bits uint) Option {
+	if bits == 0 {
+		panic("calculator: precision must be greater than 0")
+	}
+	return func(c *Calculator) {
+		c.precision = bits
+	}
+}
+
+// BigFloatEnv holds the values of the variables that are used in the expression, see Calculator.EvalBigFloat
+type BigFloatEnv map[string]*big.Float
+
+// BigFloatCalculatable is a node of the expression tree that can be calculated with the precision of `prec` bits
+type BigFloatCalculatable interface {
+	CalculateBigFloat(env BigFloatEnv, prec uint) (*big.Float, error)
+}
+
+// EvalBigFloat calculates given mathematical expression with big.Float, with the precision set by WithPrecision.
+// "^" and the built-in functions are calculated with the same precision.
+// Custom functions and custom operators are calculated with float64, so they are reported as ErrNotExact.
+// Division by zero, NaN and overflow are always reported as EvalError, like in strict mode
+func (c Calculator) EvalBigFloat(input string) (*big.Float, error) {
+	return c.EvalBigFloatWith(input, nil)
+}
+
+// EvalBigFloatWith calculates given mathematical expression with big.Float using the values of the variables in `vars`.
+// Variables with a nil value are unknown, like the ones that are not in `vars`. See #EvalBigFloat
+func (c Calculator) EvalBigFloatWith(input string, vars map[string]*big.Float) (*big.Float, error) {
+	// big.Float results are always checked, so they are not wrapped in StrictNode
+	c.strict = false
+	headNode, err := c.compile(input)
+	if err != nil {
+		return nil, err
+	}
+	return calculateBigFloat(headNode, vars, c.precision)
+}
+
+// calculateBigFloat calculates the node with big.Float. Nodes that don't support it are reported as ErrNotExact
+func calculateBigFloat(node Calculatable, env BigFloatEnv, prec uint) (*big.Float, error) {
+	bigNode, ok := node.(BigFloatCalculatable)
+	if !ok {
+		return nil, EvalError{ErrNotExact, -1, -1}
+	}
+	return bigNode.CalculateBigFloat(env, prec)
+}
+
+// calculateBigFloatOperands calculates the left and the right operands of a binary node with big.Float
+func calculateBigFloatOperands(left, right Calculatable, env BigFloatEnv, prec uint) (*big.Float, *big.Float, error) {
+	a, err := calculateBigFloat(left, env, prec)
+	if err != nil {
+		return nil, nil, err
+	}
+	b, err := calculateBigFloat(right, env, prec)
+	if err != nil {
+		return nil, nil, err
+	}
+	return a, b, nil
+}
+
+// checkBigFloat reports the result that overflowed the exponent of big.Float as ErrOverflow
+func checkBigFloat(res *big.Float, startPos, endPos int) (*big.Float, error) {
+	if res.IsInf() {
+		return nil, EvalError{ErrOverflow, startPos, endPos}
+	}
+	return res, nil
+}
+
+// parseBigFloat converts the valid numeric literal to big.Float.
+// ok is false if the exponent of the literal is too large for big.Float
+func parseBigFloat(literal string, prec uint) (f *big.Float, ok bool) {
+	literal = strings.ReplaceAll(literal, "_", "")
+	if n, ok := parseRadixLiteral(literal); ok {
+		return newFloat(prec).SetInt(n), true
+	}
+	f, _, err := newFloat(prec).Parse(literal, 10)
+	return f, err == nil && !f.IsInf()
+}
+
+func (n NumNode) CalculateBigFloat(env BigFloatEnv, prec uint) (*big.Float, error) {
+	f, ok := parseBigFloat(n.Literal, prec)
+	if !ok {
+		return nil, EvalError{ErrOverflow, n.StartPos, n.EndPos}
+	}
+	return f, nil
+}
+
+func (n VarNode) CalculateBigFloat(env BigFloatEnv, prec uint) (*big.Float, error) {
+	val, ok := env[n.Name]
+	if !ok || val == nil {
+		return nil, EvalError{ErrUnknownVariable, n.StartPos, n.EndPos}
+	}
+	// the result is modified by the parent nodes, so the value of the variable is copied
+	return checkBigFloat(newFloat(prec).Set(val), n.StartPos, n.EndPos)
+}
+
+func (n AddNode) CalculateBigFloat(env BigFloatEnv, prec uint) (*big.Float, error) {
+	a, b, err := calculateBigFloatOperands(n.Left, n.Right, env, prec)
+	if err != nil {
+		return nil, err
+	}
+	return checkBigFloat(a.Add(a, b), n.StartPos, n.EndPos)
+}
+
+func (n SubNode) CalculateBigFloat(env BigFloatEnv, prec uint) (*big.Float, error) {
+	a, b, err := calculateBigFloatOperands(n.Left, n.Right, env, prec)
+	if err != nil {
+		return nil, err
+	}
+	return checkBigFloat(a.Sub(a, b), n.StartPos, n.EndPos)
+}
+
+func (n NegNode) CalculateBigFloat(env BigFloatEnv, prec uint) (*big.Float, error) {
+	a, err := calculateBigFloat(n.Value, env, prec)
+	if err != nil {
+		return nil, err
+	}
+	return a.Neg(a), nil
+}
+
+func (n MulNode) CalculateBigFloat(env BigFloatEnv, prec uint) (*big.Float, error) {
+	a, b, err := calculateBigFloatOperands(n.Left, n.Right, env, prec)
+	if err != nil {
+		return nil, err
+	}
+	return checkBigFloat(a.Mul(a, b), n.StartPos, n.EndPos)
+}
+
+func (n DivNode) CalculateBigFloat(env BigFloatEnv, prec uint) (*big.Float, error) {
+	a, b, err := calculateBigFloatOperands(n.Left, n.Right, env, prec)
+	if err != nil {
+		return nil, err
+	}
+	if b.Sign() == 0 {
+		return nil, EvalError{ErrDivisionByZero, n.StartPos, n.EndPos}
+	}
+	return checkBigFloat(a.Quo(a, b), n.StartPos, n.EndPos)
+}
+
+func (n PowNode) CalculateBigFloat(env BigFloatEnv, prec uint) (*big.Float, error) {
+	a, b, err := calculateBigFloatOperands(n.Left, n.Right, env, prec)
+	if err != nil {
+		return nil, err
+	}
+	res, err := bigPow(a, b, prec)
+	if err != nil {
+		return nil, EvalError{err, n.StartPos, n.EndPos}
+	}
+	return res, nil
+}
+
+func (n RemNode) CalculateBigFloat(env BigFloatEnv, prec uint) (*big.Float, error) {
+	a, b, err := calculateBigFloatOperands(n.Left, n.Right, env, prec)
+	if err != nil {
+		return nil, err
+	}
+	if b.Sign() == 0 {
+		return nil, EvalError{ErrDivisionByZero, n.StartPos, n.EndPos}
+	}
+	res, err := bigMod(a, b, prec)
+	if err != nil {
+		return nil, EvalError{err, n.StartPos, n.EndPos}
+	}
+	return res, nil
+}
+
+// CalculateBigFloat calls the big.Float implementation of the built-in function.
+// Custom functions don't have one, so they are reported as ErrNotExact
+func (n FuncNode) CalculateBigFloat(env BigFloatEnv, prec uint) (*big.Float, error) {
+	if n.BigFn == nil {
+		return nil, EvalError{ErrNotExact, n.StartPos, n.EndPos}
+	}
+	args := make([]*big.Float, len(n.Args))
+	for i, arg := range n.Args {
+		val, err := calculateBigFloat(arg, env, prec)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = val
+	}
+	res, err := n.BigFn(prec, args...)
+	if err != nil {
+		return nil, EvalError{err, n.StartPos, n.EndPos}
+	}
+	return checkBigFloat(res, n.StartPos, n.EndPos)
+}
+
+// CalculateBigFloat reports ErrNotExact, because custom operators are calculated with float64
+func (n OperatorNode) CalculateBigFloat(env BigFloatEnv, prec uint) (*big.Float, error) {
+	return nil, EvalError{ErrNotExact, n.StartPos, n.EndPos}
+}
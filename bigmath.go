@@ -0,0 +1,645 @@
+package calculator
+
+import (
+	"math"
+	"math/big"
+)
+
+// guardBits is the extra precision that the big math functions calculate with, so that the rounding errors
+// of the intermediate steps don't reach the requested precision
+const guardBits = 64
+
+// maxBigExp is the largest argument of exp that doesn't overflow the exponent of big.Float
+const maxBigExp = 1e9
+
+// maxBigReduction is the largest binary exponent of the arguments that sin, cos, tan and mod reduce.
+// The reduction keeps all of the integer bits of the quotient, so larger arguments would need π or the quotient
+// with too many bits, and they are reported as ErrOverflow
+const maxBigReduction = 1 << 14
+
+// bigFunction calculates a built-in function with the precision of `prec` bits.
+// Errors are ErrNotANumber, ErrDivisionByZero and ErrOverflow, same as in strict mode
+type bigFunction func(prec uint, args ...*big.Float) (*big.Float, error)
+
+// unaryBigFunction creates a 1 argument bigFunction
+func unaryBigFunction(fn func(x *big.Float, prec uint) (*big.Float, error)) bigFunction {
+	return func(prec uint, args ...*big.Float) (*big.Float, error) {
+		return fn(args[0], prec)
+	}
+}
+
+// binaryBigFunction creates a 2 argument bigFunction
+func binaryBigFunction(fn func(x, y *big.Float, prec uint) (*big.Float, error)) bigFunction {
+	return func(prec uint, args ...*big.Float) (*big.Float, error) {
+		return fn(args[0], args[1], prec)
+	}
+}
+
+// builtinBigFunctions are the big.Float implementations of the builtinFunctions
+var builtinBigFunctions = map[string]bigFunction{
+	"abs":   unaryBigFunction(bigAbs),
+	"ceil":  unaryBigFunction(bigCeil),
+	"floor": unaryBigFunction(bigFloor),
+	"round": unaryBigFunction(bigRound),
+	"trunc": unaryBigFunction(bigTrunc),
+	"sqrt":  unaryBigFunction(bigSqrt),
+	"cbrt":  unaryBigFunction(bigCbrt),
+	"exp":   unaryBigFunction(bigExp),
+	"log":   unaryBigFunction(bigLog),
+	"log2":  unaryBigFunction(bigLog2),
+	"log10": unaryBigFunction(bigLog10),
+	"sin":   unaryBigFunction(bigSin),
+	"cos":   unaryBigFunction(bigCos),
+	"tan":   unaryBigFunction(bigTan),
+	"asin":  unaryBigFunction(bigAsin),
+	"acos":  unaryBigFunction(bigAcos),
+	"atan":  unaryBigFunction(bigAtan),
+	"sinh":  unaryBigFunction(bigSinh),
+	"cosh":  unaryBigFunction(bigCosh),
+	"tanh":  unaryBigFunction(bigTanh),
+	"atan2": binaryBigFunction(bigAtan2),
+	"pow":   binaryBigFunction(bigPow),
+	"hypot": binaryBigFunction(bigHypot),
+	"mod":   binaryBigFunction(bigMod),
+	"min": func(prec uint, args ...*big.Float) (*big.Float, error) {
+		res := args[0]
+		for _, v := range args[1:] {
+			if v.Cmp(res) < 0 {
+				res = v
+			}
+		}
+		return newFloat(prec).Set(res), nil
+	},
+	"max": func(prec uint, args ...*big.Float) (*big.Float, error) {
+		res := args[0]
+		for _, v := range args[1:] {
+			if v.Cmp(res) > 0 {
+				res = v
+			}
+		}
+		return newFloat(prec).Set(res), nil
+	},
+}
+
+// newFloat creates a zero big.Float with the precision of `prec` bits
+func newFloat(prec uint) *big.Float {
+	return new(big.Float).SetPrec(prec)
+}
+
+// exponent returns the binary exponent of x, so that |x| < 2^exponent. 0 has the exponent 0
+func exponent(x *big.Float) int {
+	return x.MantExp(nil)
+}
+
+// isNegligible checks if `term` doesn't change `sum` with the precision of `prec` bits
+func isNegligible(term, sum *big.Float, prec uint) bool {
+	return term.Sign() == 0 || exponent(term) < exponent(sum)-int(prec)
+}
+
+// bigPi calculates π with Machin's formula, π = 16·atan(1/5) - 4·atan(1/239)
+func bigPi(prec uint) *big.Float {
+	wp := prec + guardBits
+	a := atanInv(5, wp)
+	a.Mul(a, newFloat(wp).SetInt64(16))
+	b := atanInv(239, wp)
+	b.Mul(b, newFloat(wp).SetInt64(4))
+	return a.Sub(a, b).SetPrec(prec)
+}
+
+// atanInv calculates atan(1/n) with the Taylor series, which converges fast for large `n`
+func atanInv(n int64, prec uint) *big.Float {
+	x := newFloat(prec).SetInt64(1)
+	x.Quo(x, newFloat(prec).SetInt64(n))
+	n2 := newFloat(prec).SetInt64(n * n)
+	sum := newFloat(prec).Set(x)
+	term := newFloat(prec)
+	for k := int64(1); ; k++ {
+		x.Quo(x, n2)
+		term.Quo(x, newFloat(prec).SetInt64(2*k+1))
+		if isNegligible(term, sum, prec) {
+			return sum
+		}
+		if k%2 == 1 {
+			sum.Sub(sum, term)
+		} else {
+			sum.Add(sum, term)
+		}
+	}
+}
+
+// atanh calculates atanh(z) with the Taylor series, for small `z`
+func atanh(z *big.Float, prec uint) *big.Float {
+	sum := newFloat(prec).Set(z)
+	if z.Sign() == 0 {
+		return sum
+	}
+	z2 := newFloat(prec).Mul(z, z)
+	power := newFloat(prec).Set(z)
+	term := newFloat(prec)
+	for k := int64(1); ; k++ {
+		power.Mul(power, z2)
+		term.Quo(power, newFloat(prec).SetInt64(2*k+1))
+		if isNegligible(term, sum, prec) {
+			return sum
+		}
+		sum.Add(sum, term)
+	}
+}
+
+// bigLn2 calculates ln(2) = 2·atanh(1/3)
+func bigLn2(prec uint) *big.Float {
+	third := newFloat(prec).SetInt64(1)
+	third.Quo(third, newFloat(prec).SetInt64(3))
+	res := atanh(third, prec)
+	return res.Add(res, res)
+}
+
+func bigAbs(x *big.Float, prec uint) (*big.Float, error) {
+	return newFloat(prec).Abs(x), nil
+}
+
+func bigTrunc(x *big.Float, prec uint) (*big.Float, error) {
+	if x.IsInt() {
+		return newFloat(prec).Set(x), nil
+	}
+	n, _ := x.Int(nil)
+	return newFloat(prec).SetInt(n), nil
+}
+
+func bigFloor(x *big.Float, prec uint) (*big.Float, error) {
+	res, _ := bigTrunc(x, prec)
+	if x.Sign() < 0 && !x.IsInt() {
+		res.Sub(res, newFloat(prec).SetInt64(1))
+	}
+	return res, nil
+}
+
+func bigCeil(x *big.Float, prec uint) (*big.Float, error) {
+	res, _ := bigTrunc(x, prec)
+	if x.Sign() > 0 && !x.IsInt() {
+		res.Add(res, newFloat(prec).SetInt64(1))
+	}
+	return res, nil
+}
+
+// bigRound rounds half away from zero, like math.Round
+func bigRound(x *big.Float, prec uint) (*big.Float, error) {
+	if x.IsInt() {
+		return newFloat(prec).Set(x), nil
+	}
+	// x is not an integer, so it has less than x.Prec() integer bits, and adding 0.5 is exact
+	half := newFloat(x.Prec() + 2).SetFloat64(0.5)
+	if x.Sign() < 0 {
+		half.Neg(half)
+	}
+	return bigTrunc(half.Add(half, x), prec)
+}
+
+func bigSqrt(x *big.Float, prec uint) (*big.Float, error) {
+	if x.Sign() < 0 {
+		return nil, ErrNotANumber
+	}
+	return newFloat(prec).Sqrt(x), nil
+}
+
+// bigCbrt finds the cube root with Newton's method, starting from the float64 estimate
+func bigCbrt(x *big.Float, prec uint) (*big.Float, error) {
+	if x.Sign() == 0 {
+		return newFloat(prec), nil
+	}
+	wp := prec + guardBits
+	a := newFloat(wp).Abs(x)
+	// a = mant·2^exp, where exp is a multiple of 3, so that the estimate is cbrt(mant)·2^(exp/3)
+	mant := newFloat(wp)
+	exp := a.MantExp(mant)
+	shift := ((exp % 3) + 3) % 3
+	mant.SetMantExp(mant, shift)
+	exp -= shift
+	m, _ := mant.Float64()
+	y := newFloat(wp).SetFloat64(math.Cbrt(m))
+	y.SetMantExp(y, exp/3)
+
+	three := newFloat(wp).SetInt64(3)
+	t := newFloat(wp)
+	// every iteration doubles the correct bits, starting from the 53 bits of float64
+	for correct := uint(50); correct < 2*wp; correct *= 2 {
+		// y = (2y + a/y²) / 3
+		t.Mul(y, y)
+		t.Quo(a, t)
+		y.Add(y, y)
+		y.Add(y, t)
+		y.Quo(y, three)
+	}
+	if x.Sign() < 0 {
+		y.Neg(y)
+	}
+	return y.SetPrec(prec), nil
+}
+
+// bigExp calculates e^x with the Taylor series of x/2^n, and squares the result n times
+func bigExp(x *big.Float, prec uint) (*big.Float, error) {
+	if x.Cmp(big.NewFloat(maxBigExp)) > 0 {
+		return nil, ErrOverflow
+	}
+	if x.Cmp(big.NewFloat(-maxBigExp)) < 0 {
+		return newFloat(prec), nil
+	}
+	n := 0
+	if exp := exponent(x); exp > -8 && x.Sign() != 0 {
+		n = exp + 8
+	}
+	// every squaring doubles the relative error
+	wp := prec + guardBits + uint(n)
+	r := newFloat(wp).SetMantExp(x, -n)
+	sum := newFloat(wp).SetInt64(1)
+	term := newFloat(wp).SetInt64(1)
+	for k := int64(1); ; k++ {
+		term.Mul(term, r)
+		term.Quo(term, newFloat(wp).SetInt64(k))
+		if isNegligible(term, sum, wp) {
+			break
+		}
+		sum.Add(sum, term)
+	}
+	for i := 0; i < n; i++ {
+		sum.Mul(sum, sum)
+	}
+	return sum.SetPrec(prec), nil
+}
+
+// bigLog calculates ln(x) = ln(m) + e·ln(2), where x = m·2^e and m is close to 1, so that ln(m) = 2·atanh((m-1)/(m+1)) converges fast
+func bigLog(x *big.Float, prec uint) (*big.Float, error) {
+	// log(0) is -Inf, which is too large for any precision
+	if x.Sign() == 0 {
+		return nil, ErrOverflow
+	}
+	if x.Sign() < 0 {
+		return nil, ErrNotANumber
+	}
+	wp := prec + guardBits
+	m := newFloat(wp)
+	e := x.MantExp(m)
+	// m in [0.5, 1) is moved to [0.7, 1.4), so that ln(x) of the numbers close to 1 doesn't lose precision
+	if m.Cmp(big.NewFloat(math.Sqrt2/2)) < 0 {
+		m.SetMantExp(m, 1)
+		e--
+	}
+	one := newFloat(wp).SetInt64(1)
+	z := newFloat(wp).Sub(m, one)
+	z.Quo(z, newFloat(wp).Add(m, one))
+	res := atanh(z, wp)
+	res.Add(res, res)
+	if e != 0 {
+		ln2 := bigLn2(wp)
+		res.Add(res, ln2.Mul(ln2, newFloat(wp).SetInt64(int64(e))))
+	}
+	return res.SetPrec(prec), nil
+}
+
+func bigLog2(x *big.Float, prec uint) (*big.Float, error) {
+	res, err := bigLog(x, prec+guardBits)
+	if err != nil {
+		return nil, err
+	}
+	return res.Quo(res, bigLn2(prec+guardBits)).SetPrec(prec), nil
+}
+
+func bigLog10(x *big.Float, prec uint) (*big.Float, error) {
+	res, err := bigLog(x, prec+guardBits)
+	if err != nil {
+		return nil, err
+	}
+	ln10, _ := bigLog(newFloat(prec+guardBits).SetInt64(10), prec+guardBits)
+	return res.Quo(res, ln10).SetPrec(prec), nil
+}
+
+// bigSinCos calculates sin(x) and cos(x) with the Taylor series, after reducing x to [-π, π].
+// Arguments larger than 2^maxBigReduction are reported as ErrOverflow
+func bigSinCos(x *big.Float, prec uint) (sin, cos *big.Float, err error) {
+	exp := exponent(x)
+	if exp > maxBigReduction {
+		return nil, nil, ErrOverflow
+	}
+	// the integer bits of x are lost by the reduction
+	wp := prec + guardBits
+	if exp > 0 {
+		wp += uint(exp)
+	}
+	r := newFloat(wp).Set(x)
+	twoPi := bigPi(wp)
+	twoPi.Add(twoPi, twoPi)
+	q := newFloat(wp).Quo(r, twoPi)
+	q, _ = bigRound(q, wp)
+	r.Sub(r, q.Mul(q, twoPi))
+
+	sin = newFloat(wp).Set(r)
+	cos = newFloat(wp).SetInt64(1)
+	// term is r^k / k!, the odd ones belong to sin and the even ones to cos
+	term := newFloat(wp).Set(r)
+	for k := int64(2); ; k++ {
+		term.Mul(term, r)
+		term.Quo(term, newFloat(wp).SetInt64(k))
+		if isNegligible(term, sin, wp) && isNegligible(term, cos, wp) {
+			break
+		}
+		switch k % 4 {
+		case 0:
+			cos.Add(cos, term)
+		case 1:
+			sin.Add(sin, term)
+		case 2:
+			cos.Sub(cos, term)
+		case 3:
+			sin.Sub(sin, term)
+		}
+	}
+	return sin.SetPrec(prec), cos.SetPrec(prec), nil
+}
+
+func bigSin(x *big.Float, prec uint) (*big.Float, error) {
+	sin, _, err := bigSinCos(x, prec)
+	return sin, err
+}
+
+func bigCos(x *big.Float, prec uint) (*big.Float, error) {
+	_, cos, err := bigSinCos(x, prec)
+	return cos, err
+}
+
+func bigTan(x *big.Float, prec uint) (*big.Float, error) {
+	sin, cos, err := bigSinCos(x, prec+guardBits)
+	if err != nil {
+		return nil, err
+	}
+	return sin.Quo(sin, cos).SetPrec(prec), nil
+}
+
+// bigAtan reduces |x| below 1/16 with atan(x) = 2·atan(x / (1 + sqrt(1 + x²))), and calculates the Taylor series
+func bigAtan(x *big.Float, prec uint) (*big.Float, error) {
+	wp := prec + guardBits
+	a := newFloat(wp).Abs(x)
+	one := newFloat(wp).SetInt64(1)
+	invert := a.Cmp(one) > 0
+	if invert {
+		a.Quo(one, a)
+	}
+	halvings := 0
+	t := newFloat(wp)
+	for a.Cmp(big.NewFloat(1.0/16)) > 0 {
+		t.Mul(a, a)
+		t.Add(t, one)
+		t.Sqrt(t)
+		t.Add(t, one)
+		a.Quo(a, t)
+		halvings++
+	}
+	res := newFloat(wp).Set(a)
+	if a.Sign() != 0 {
+		a2 := newFloat(wp).Mul(a, a)
+		power := newFloat(wp).Set(a)
+		term := newFloat(wp)
+		for k := int64(1); ; k++ {
+			power.Mul(power, a2)
+			term.Quo(power, newFloat(wp).SetInt64(2*k+1))
+			if isNegligible(term, res, wp) {
+				break
+			}
+			if k%2 == 1 {
+				res.Sub(res, term)
+			} else {
+				res.Add(res, term)
+			}
+		}
+	}
+	res.SetMantExp(res, halvings)
+	if invert {
+		halfPi := bigPi(wp)
+		halfPi.SetMantExp(halfPi, -1)
+		res.Sub(halfPi, res)
+	}
+	if x.Sign() < 0 {
+		res.Neg(res)
+	}
+	return res.SetPrec(prec), nil
+}
+
+// bigAsin calculates asin(x) = atan(x / sqrt(1 - x²))
+func bigAsin(x *big.Float, prec uint) (*big.Float, error) {
+	wp := prec + guardBits
+	one := newFloat(wp).SetInt64(1)
+	switch newFloat(wp).Abs(x).Cmp(one) {
+	case 1:
+		return nil, ErrNotANumber
+	case 0:
+		halfPi := bigPi(prec)
+		halfPi.SetMantExp(halfPi, -1)
+		if x.Sign() < 0 {
+			halfPi.Neg(halfPi)
+		}
+		return halfPi, nil
+	}
+	t := newFloat(wp).Mul(x, x)
+	t.Sub(one, t)
+	t.Sqrt(t)
+	t.Quo(x, t)
+	return bigAtan(t, prec)
+}
+
+// bigAcos calculates acos(x) = π/2 - asin(x)
+func bigAcos(x *big.Float, prec uint) (*big.Float, error) {
+	wp := prec + guardBits
+	res, err := bigAsin(x, wp)
+	if err != nil {
+		return nil, err
+	}
+	halfPi := bigPi(wp)
+	halfPi.SetMantExp(halfPi, -1)
+	return res.Sub(halfPi, res).SetPrec(prec), nil
+}
+
+// bigAtan2 calculates the angle of the point (x, y), like math.Atan2
+func bigAtan2(y, x *big.Float, prec uint) (*big.Float, error) {
+	wp := prec + guardBits
+	if x.Sign() == 0 {
+		if y.Sign() == 0 {
+			return newFloat(prec), nil
+		}
+		halfPi := bigPi(prec)
+		halfPi.SetMantExp(halfPi, -1)
+		if y.Sign() < 0 {
+			halfPi.Neg(halfPi)
+		}
+		return halfPi, nil
+	}
+	res, _ := bigAtan(newFloat(wp).Quo(y, x), wp)
+	if x.Sign() < 0 {
+		if y.Sign() < 0 {
+			res.Sub(res, bigPi(wp))
+		} else {
+			res.Add(res, bigPi(wp))
+		}
+	}
+	return res.SetPrec(prec), nil
+}
+
+// bigExpPair calculates e^x and e^-x for the hyperbolic functions.
+// Small x needs more precision, because e^x - e^-x cancels the leading bits
+func bigExpPair(x *big.Float, prec uint) (ex, enx *big.Float, err error) {
+	wp := prec + guardBits
+	if exp := exponent(x); exp < 0 {
+		wp += uint(-exp)
+	}
+	ex, err = bigExp(x, wp)
+	if err != nil {
+		return nil, nil, err
+	}
+	enx, err = bigExp(newFloat(wp).Neg(x), wp)
+	if err != nil {
+		return nil, nil, err
+	}
+	return ex, enx, nil
+}
+
+func bigSinh(x *big.Float, prec uint) (*big.Float, error) {
+	ex, enx, err := bigExpPair(x, prec)
+	if err != nil {
+		return nil, err
+	}
+	ex.Sub(ex, enx)
+	return ex.SetMantExp(ex, -1).SetPrec(prec), nil
+}
+
+func bigCosh(x *big.Float, prec uint) (*big.Float, error) {
+	ex, enx, err := bigExpPair(x, prec)
+	if err != nil {
+		return nil, err
+	}
+	ex.Add(ex, enx)
+	return ex.SetMantExp(ex, -1).SetPrec(prec), nil
+}
+
+func bigTanh(x *big.Float, prec uint) (*big.Float, error) {
+	// tanh(x) is ±1 with any precision, way before e^x overflows
+	if newFloat(prec).Abs(x).Cmp(big.NewFloat(maxBigExp)) > 0 {
+		return newFloat(prec).SetInt64(int64(x.Sign())), nil
+	}
+	ex, enx, err := bigExpPair(x, prec)
+	if err != nil {
+		return nil, err
+	}
+	num := newFloat(ex.Prec()).Sub(ex, enx)
+	return num.Quo(num, ex.Add(ex, enx)).SetPrec(prec), nil
+}
+
+// bigPow calculates x^y. Integer exponents are calculated by squaring, others as e^(y·ln(x)).
+// Negative x can only be raised to integer exponents, and the exponents that are too large for int64 are even
+// unless all of their bits fit in the mantissa
+func bigPow(x, y *big.Float, prec uint) (*big.Float, error) {
+	if y.Sign() == 0 {
+		return newFloat(prec).SetInt64(1), nil
+	}
+	if x.Sign() == 0 {
+		if y.Sign() < 0 {
+			return nil, ErrDivisionByZero
+		}
+		return newFloat(prec), nil
+	}
+	if n, acc := y.Int64(); acc == big.Exact {
+		return bigPowInt(x, n, prec)
+	}
+	if x.Sign() < 0 && !y.IsInt() {
+		return nil, ErrNotANumber
+	}
+	// |y| >= 2^63 here, so y is odd only if its lowest mantissa bit is the bit of 2^0
+	negative := x.Sign() < 0 && exponent(y) == int(y.MinPrec())
+	res, err := bigPowAbs(new(big.Float).Abs(x), y, prec)
+	if err != nil {
+		return nil, err
+	}
+	if negative {
+		res.Neg(res)
+	}
+	return res, nil
+}
+
+// bigPowAbs calculates x^y = e^(y·ln(x)) for x > 0
+func bigPowAbs(x, y *big.Float, prec uint) (*big.Float, error) {
+	// 1 stays 1 with any exponent, while y·ln(x) would be rounded to a tiny number
+	if x.Cmp(big.NewFloat(1)) == 0 {
+		return newFloat(prec).SetInt64(1), nil
+	}
+	wp := prec + guardBits
+	ln, _ := bigLog(x, wp)
+	t := newFloat(wp).Mul(ln, y)
+	// e^t overflows or underflows to 0 before the extra precision below could be used,
+	// and it would grow with the size of t
+	if newFloat(wp).Abs(t).Cmp(big.NewFloat(maxBigExp)) > 0 {
+		if t.Sign() > 0 {
+			return nil, ErrOverflow
+		}
+		return newFloat(prec), nil
+	}
+	// the error of t is multiplied by e^t, so the integer bits of t need more precision
+	if exp := exponent(t); exp > 0 {
+		ln, _ = bigLog(x, wp+uint(exp))
+		t = newFloat(wp+uint(exp)).Mul(ln, y)
+	}
+	return bigExp(t, prec)
+}
+
+// bigPowInt calculates x^n by squaring
+func bigPowInt(x *big.Float, n int64, prec uint) (*big.Float, error) {
+	negative := n < 0
+	if negative {
+		n = -n
+	}
+	// every multiplication adds a rounding error
+	wp := prec + guardBits + 64
+	res := newFloat(wp).SetInt64(1)
+	base := newFloat(wp).Set(x)
+	for ; n > 0; n >>= 1 {
+		if n&1 == 1 {
+			res.Mul(res, base)
+		}
+		if n > 1 {
+			base.Mul(base, base)
+		}
+	}
+	if negative {
+		res.Quo(newFloat(wp).SetInt64(1), res)
+	}
+	if res.IsInf() {
+		return nil, ErrOverflow
+	}
+	return res.SetPrec(prec), nil
+}
+
+func bigHypot(x, y *big.Float, prec uint) (*big.Float, error) {
+	wp := prec + guardBits
+	res := newFloat(wp).Mul(x, x)
+	res.Add(res, newFloat(wp).Mul(y, y))
+	if res.IsInf() {
+		return nil, ErrOverflow
+	}
+	return res.Sqrt(res).SetPrec(prec), nil
+}
+
+// bigMod calculates the remainder of x/y with the sign of x, like math.Mod
+func bigMod(x, y *big.Float, prec uint) (*big.Float, error) {
+	if y.Sign() == 0 {
+		return nil, ErrNotANumber
+	}
+	diff := exponent(x) - exponent(y)
+	if diff > maxBigReduction {
+		return nil, ErrOverflow
+	}
+	// the quotient must keep all of its integer bits
+	wp := prec + guardBits
+	if diff > 0 {
+		wp += uint(diff)
+	}
+	q := newFloat(wp).Quo(x, y)
+	q, _ = bigTrunc(q, wp)
+	res := newFloat(wp).Sub(x, q.Mul(q, y))
+	return res.SetPrec(prec), nil
+}
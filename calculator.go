@@ -3,37 +3,127 @@ package calculator
 import (
 	"errors"
 	"fmt"
+	"math/big"
 	"strconv"
+	"strings"
 
 	"github.com/DavudSafarli/design-calculator-challenge/lexer"
 )
 
-// BODMAS
-var precedence = map[int]int{
-	POW: 3,
-	MUL: 2,
-	DIV: 2,
-	ADD: 1,
-	SUB: 1,
-}
-
 // Calculator evaluates the given arithmetic expression.
 type Calculator struct {
-	lexer lexer.Lexer
+	lexer     lexer.Lexer
+	functions map[string]Function
+	// binary and prefix operators by their symbols
+	binaryOperators map[string]OperatorSpec
+	prefixOperators map[string]OperatorSpec
+	strict          bool
+	allErrors       bool
+	// typed builds the operators as OverloadNode, see CompileTyped
+	typed bool
+	// precision of EvalBigFloat in bits, and the big.Float implementations of the functions
+	precision    uint
+	bigFunctions map[string]bigFunction
+	decimalMode  DecimalMode
 }
 
-func New() Calculator {
-	lexer := buildLexerWithBODMASSupport()
-	return Calculator{
-		lexer: lexer,
+// Option configures the Calculator created by New
+type Option func(*Calculator)
+
+// WithFunction makes the function callable by its name in the expressions of the Calculator.
+// Functions are registered per Calculator, and can override the built-in functions.
+// They are calculated with float64, so they cannot be used by EvalBigFloat.
+// Panics if the name is not an identifier, or the Function is invalid
+// example:
+// calculator.New(calculator.WithFunction("clamp", calculator.Function{3, 3, clamp}))
+func WithFunction(name string, fn Function) Option {
+	fn.validate(name)
+	return func(c *Calculator) {
+		c.functions[name] = fn
+		delete(c.bigFunctions, name)
+	}
+}
+
+// WithOperators adds the operators to the Calculator, on top of the DefaultOperators.
+// An operator replaces the existing operator with the same Symbol and Arity.
+// Panics if the OperatorSpec is invalid
+// example:
+// calculator.New(calculator.WithOperators(calculator.OperatorSpec{"%", 2, calculator.LeftAssociative, 2, mod}))
+func WithOperators(specs ...OperatorSpec) Option {
+	return func(c *Calculator) {
+		for _, spec := range specs {
+			c.addOperator(spec)
+		}
 	}
 }
 
+// WithStrictMode makes the Calculator report division by zero, NaN and overflow to Inf as EvalError
+// with the position of the operator or the function call that produced it.
+// Numbers that are too large for float64, like "1e400", are reported as ErrOverflow with the position of the number.
+// Without it, the result is calculated as it is with float64, e.g. "1/0" is +Inf.
+// It applies to Eval, Compile and the Float results of EvalValue and CompileTyped
+func WithStrictMode() Option {
+	return func(c *Calculator) {
+		c.strict = true
+	}
+}
+
+// WithAllErrors makes the Calculator report all the validation errors of the expression at once, as EvalErrors.
+// Without it, only the first validation error is reported
+func WithAllErrors() Option {
+	return func(c *Calculator) {
+		c.allErrors = true
+	}
+}
+
+func New(opts ...Option) Calculator {
+	c := Calculator{
+		functions:       make(map[string]Function, len(builtinFunctions)),
+		binaryOperators: map[string]OperatorSpec{},
+		prefixOperators: map[string]OperatorSpec{},
+		precision:       DefaultPrecision,
+		decimalMode:     DefaultDecimalMode,
+		bigFunctions:    make(map[string]bigFunction, len(builtinBigFunctions)),
+	}
+	for name, fn := range builtinFunctions {
+		c.functions[name] = fn
+	}
+	for name, fn := range builtinBigFunctions {
+		c.bigFunctions[name] = fn
+	}
+	for _, spec := range DefaultOperators() {
+		c.addOperator(spec)
+	}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	c.lexer = buildLexerWithBODMASSupport(operatorSymbols(c.binaryOperators, c.prefixOperators))
+	return c
+}
+
+func (c *Calculator) addOperator(spec OperatorSpec) {
+	spec.validate()
+	if spec.Arity == 1 {
+		c.prefixOperators[spec.Symbol] = spec
+	} else {
+		c.binaryOperators[spec.Symbol] = spec
+	}
+}
+
+// operatorOf returns the OperatorSpec of the OP or UNARY_OP token
+func (c Calculator) operatorOf(token Token) OperatorSpec {
+	if token.IsUnaryOP() {
+		return c.prefixOperators[token.Value]
+	}
+	return c.binaryOperators[token.Value]
+}
+
 // EvalError gives info about what was the error, where it starts and where it ends in the user input
 // example:
 // user input: " 1+*"
 // err: EvalError{Err2Operators, 3, 4}
-// (3, 4) represents the position of "*" which can be used to visualize the error in the front-facing application
+// (3, 4) represents the position of "*" which can be used to visualize the error in the front-facing application.
+// Positions are counted in runes, use `Positions` to get the byte, UTF-16 and line/column positions
 type EvalError struct {
 	Err      error
 	StartPos int
@@ -44,86 +134,161 @@ func (e EvalError) Error() string {
 	return fmt.Sprintf("%s in position (%v, %v)", e.Err.Error(), e.StartPos, e.EndPos)
 }
 
+// EvalErrors is the list of all the validation errors, reported when Calculator is created WithAllErrors
+type EvalErrors []EvalError
+
+func (e EvalErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
 // Eval calculates given mathematical expression and returns the result
 func (c Calculator) Eval(input string) (float64, error) {
-	return c.eval(input)
+	return c.eval(input, nil)
+}
+
+// EvalWith calculates given mathematical expression using the values of the variables in `vars`
+// example:
+// c.EvalWith("price * qty * (1 + tax)", map[string]float64{"price": 10, "qty": 3, "tax": 0.2}) // 36
+func (c Calculator) EvalWith(input string, vars map[string]float64) (float64, error) {
+	return c.eval(input, vars)
 }
 
-// eval calculates the expression by going through following steps:
+// Compile validates the expression and builds the expression tree once,
+// so that it can be evaluated many times with different variables without parsing it again
+func (c Calculator) Compile(input string) (*Expression, error) {
+	headNode, err := c.compile(input)
+	if err != nil {
+		return nil, err
+	}
+	return &Expression{input, headNode}, nil
+}
+
+// eval calculates the expression by compiling it and
+// running the calculation process starting from the head node of the tree and getting the result
+func (c Calculator) eval(input string, env Env) (float64, error) {
+	headNode, err := c.compile(input)
+	if err != nil {
+		return 0, err
+	}
+	return headNode.Calculate(env)
+}
+
+// compile builds the expression tree by going through following steps:
 // - performs lexical analysis which generates sequence of Tokens.
 // - validate the expression, report the error and invalid index position.
 // - parses the Tokens and builds an expression tree, where each node is a `Calculatable`.
-// - running the calculation process starting from the head node of the tree and getting the result
-func (c Calculator) eval(input string) (float64, error) {
-	lexerTokens, err := c.lexer.Lex(input)
+func (c Calculator) compile(input string) (Calculatable, error) {
+	lexerTokens, err := c.lexer.LexPositioned(input)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 	tokens := make([]Token, 0, len(lexerTokens))
 
 	// convert `lexer.Token`s to original `Token`s defined by us
 	for _, v := range lexerTokens {
-		tokens = append(tokens, v.(Token))
+		token := v.Token.(Token)
+		token.StartPos, token.EndPos = v.Start.Offset, v.End.Offset
+		tokens = append(tokens, token)
 	}
+	resolveFunctionCalls(tokens)
+	tokens = c.resolveUnaryOperators(tokens)
 
-	invalidTokenPos, err := validateExpression(tokens)
-	if err != nil {
-		if invalidTokenPos != -1 {
-			startPos, endPos := findTokenPositionInRawInput(tokens, invalidTokenPos)
-			return 0, EvalError{err, startPos, endPos}
+	if errs := c.validateExpression(tokens); len(errs) != 0 {
+		evalErrs := make(EvalErrors, 0, len(errs))
+		for _, e := range errs {
+			if e.tokenPos == -1 {
+				evalErrs = append(evalErrs, EvalError{e.err, -1, -1})
+				continue
+			}
+			evalErrs = append(evalErrs, EvalError{e.err, tokens[e.tokenPos].StartPos, tokens[e.endTokenPos].EndPos})
+		}
+		if c.allErrors {
+			return nil, evalErrs
 		}
-		return 0, EvalError{err, -1, -1}
+		return nil, evalErrs[0]
 	}
 
-	headNode := c.buildExpressionTree(tokens)
-	return headNode.Calculate(), nil
+	return c.buildExpressionTree(tokens), nil
 }
 
 // buildExpressionTree creates the expression tree and returns the head node
-// given the valid Infix slice of Tokens
+// given the valid Infix slice of Tokens, where all the functions are known and called with the right number of arguments
 func (c Calculator) buildExpressionTree(tokens []Token) Calculatable {
 	var postfix CalculatableStack
 	var operators TokenStack
 
-	// addOperandNode takes 2 node from stack, creates a new Expression Node like below,
+	// funcCall is a function call which is not closed yet
+	type funcCall struct {
+		name     string
+		fn       Function
+		startPos int
+		commas   int
+	}
+	var calls []funcCall
+
+	// addOperandNode takes 2 node (1 for prefix operators) from stack, creates a new Expression Node like below,
 	//     +
 	//   /   \
 	//  a     b
 	// and pushes it back to the slice of postfix nodes
 	addOperandNode := func(op Token) {
-		b, _ := postfix.Pop()
-		a, _ := postfix.Pop()
-		if op.IsAddOP() {
-			postfix.Push(AddNode{a, b})
+		spec := c.operatorOf(op)
+		operands := make([]Calculatable, spec.Arity)
+		for i := spec.Arity - 1; i >= 0; i-- {
+			operands[i], _ = postfix.Pop()
 		}
-		if op.IsSubOP() {
-			postfix.Push(SubNode{a, b})
-		}
-		if op.IsMulOP() {
-			postfix.Push(MulNode{a, b})
-		}
-		if op.IsDivOP() {
-			postfix.Push(DivNode{a, b})
+		if c.typed {
+			var node Calculatable = OverloadNode{spec.Symbol, spec.Fn, spec.overloads(), operands, op.StartPos, op.EndPos, spec.buildNode(operands, op.StartPos, op.EndPos)}
+			if c.strict {
+				node = StrictNode{node, op.StartPos, op.EndPos}
+			}
+			postfix.Push(node)
+			return
 		}
-		if op.IsPowOP() {
-			postfix.Push(PowNode{a, b})
+		if c.strict {
+			postfix.Push(StrictNode{spec.buildNode(operands, op.StartPos, op.EndPos), op.StartPos, op.EndPos})
+			return
 		}
+		postfix.Push(spec.buildNode(operands, op.StartPos, op.EndPos))
 	}
 	prev := Token{}
 	for _, token := range tokens {
-		if token.IsSpace() {
-			continue
-		}
 		if token.IsNum() {
-			val, _ := strconv.ParseFloat(token.Value, 64)
-			postfix.Push(NumNode{val})
+			var node Calculatable = NumNode{parseNumber(token.Value), token.Value, token.StartPos, token.EndPos}
+			if c.strict {
+				node = StrictNode{node, token.StartPos, token.EndPos}
+			}
+			postfix.Push(node)
+		} else if token.IsIdent() {
+			postfix.Push(VarNode{token.Value, token.StartPos, token.EndPos})
+		} else if token.IsFunc() {
+			calls = append(calls, funcCall{token.Value, c.functions[token.Value], token.StartPos, 0})
+			operators.Push(token)
+		} else if token.IsComma() {
+			// finish the current argument of the function
+			for {
+				prevOP, _ := operators.Top()
+				if prevOP.Type == L_PAR {
+					break
+				}
+				operators.Pop()
+				addOperandNode(prevOP)
+			}
+			calls[len(calls)-1].commas++
+		} else if token.IsUnaryOP() {
+			// there is no left operand for the unary operator, so it can't pop anything
+			operators.Push(token)
 		} else if token.IsOP() {
 			for {
 				prevOP, exists := operators.Top()
 				if !exists || prevOP.Type == L_PAR {
 					break
 				}
-				if precedence[prevOP.Type] < precedence[token.Type] {
+				if !shouldPopBefore(c.operatorOf(prevOP), c.operatorOf(token)) {
 					break
 				}
 				operators.Pop() // remove element
@@ -134,7 +299,7 @@ func (c Calculator) buildExpressionTree(tokens []Token) Calculatable {
 		} else if token.IsLeftParacentesis() {
 			// if there is a NUM before LEFT_PAR, then consider it as multiplication
 			if prev.IsNum() {
-				operators.Push(Token{MUL, ""})
+				operators.Push(Token{Type: OP, Value: "*", StartPos: token.StartPos, EndPos: token.EndPos})
 			}
 			operators.Push(token)
 		} else if token.IsRightParacentesis() {
@@ -145,6 +310,27 @@ func (c Calculator) buildExpressionTree(tokens []Token) Calculatable {
 				}
 				addOperandNode(prevOP)
 			}
+			// if paracentheses belong to a function call, then replace the arguments with the FuncNode
+			if prevOP, _ := operators.Top(); prevOP.IsFunc() {
+				operators.Pop()
+				call := calls[len(calls)-1]
+				calls = calls[:len(calls)-1]
+
+				argCount := call.commas + 1
+				// case: "f()"
+				if prev.IsLeftParacentesis() {
+					argCount = 0
+				}
+				args := make([]Calculatable, argCount)
+				for i := argCount - 1; i >= 0; i-- {
+					args[i], _ = postfix.Pop()
+				}
+				var node Calculatable = FuncNode{call.name, call.fn.Fn, args, call.startPos, token.EndPos, c.bigFunctions[call.name]}
+				if c.strict {
+					node = StrictNode{node, call.startPos, token.EndPos}
+				}
+				postfix.Push(node)
+			}
 		}
 		prev = token
 	}
@@ -157,42 +343,97 @@ func (c Calculator) buildExpressionTree(tokens []Token) Calculatable {
 	return node
 }
 
-// buildLexerWithBODMASSupport creates and returns a Lexer with lexical support for BODMAS, and SPACE
-func buildLexerWithBODMASSupport() lexer.Lexer {
+// parseNumber converts the valid numeric literal to float64.
+// Literals that are too large to fit in float64 are converted to +Inf, which is reported as ErrOverflow in strict mode
+func parseNumber(literal string) float64 {
+	literal = strings.ReplaceAll(literal, "_", "")
+	if n, ok := parseRadixLiteral(literal); ok {
+		val, _ := new(big.Float).SetInt(n).Float64()
+		return val
+	}
+	val, _ := strconv.ParseFloat(literal, 64)
+	return val
+}
+
+// parseRadixLiteral converts the literal with a radix prefix like "0xFF", or "-0x8000000000000000" for math.MinInt64, to an integer.
+// ok is false if the literal doesn't have a radix prefix
+func parseRadixLiteral(literal string) (n *big.Int, ok bool) {
+	digits := strings.TrimPrefix(literal, "-")
+	if len(digits) <= 2 || digits[0] != '0' {
+		return nil, false
+	}
+	switch digits[1] {
+	case 'x', 'X', 'o', 'O', 'b', 'B':
+		// base 0 detects the base from the prefix
+		return new(big.Int).SetString(literal, 0)
+	}
+	return nil, false
+}
+
+// buildLexerWithBODMASSupport creates and returns a Lexer with lexical support for BODMAS, variables, function calls and SPACE.
+// operatorSymbols must be sorted from longest to shortest
+func buildLexerWithBODMASSupport(operatorSymbols []string) lexer.Lexer {
 	// 1-char matcher function for Lexer
 	createOneCharMatcher := func(ch rune, tokenType int) lexer.MatcherFunc {
 		return func(l *lexer.Lexer) (token lexer.Token, found bool) {
 			ok := l.ReadChar(ch)
 			if ok {
-				return Token{tokenType, string(ch)}, true
+				return Token{Type: tokenType, Value: string(ch)}, true
 			}
 			return nil, false
 		}
 	}
-	spaces := []rune{' ', '\t', '\n'}
+	spaces := []rune{' ', '\t', '\n', '\r'}
+	opFirstRunes := ""
+	for _, symbol := range operatorSymbols {
+		opFirstRunes += string([]rune(symbol)[0])
+	}
 	return lexer.NewLexer(lexer.Options{
-		Tokens: []int{NUM, ADD, SUB, MUL, DIV, POW, L_PAR, R_PAR, SPACE},
+		Tokens: []int{NUM, IDENT, OP, L_PAR, R_PAR, COMMA, SPACE},
+		// SPACE is attached to the following Token, so it never reaches the parser
+		Trivia: []int{SPACE},
+		// IDENT can start with any letter, so it has no hint
+		FirstRunes: map[int]string{
+			NUM:   "0123456789.",
+			OP:    opFirstRunes,
+			L_PAR: "(",
+			R_PAR: ")",
+			COMMA: ",",
+			SPACE: string(spaces),
+		},
 		Matchers: map[int]lexer.MatcherFunc{
-			ADD:   createOneCharMatcher('+', ADD),
-			SUB:   createOneCharMatcher('-', SUB),
-			MUL:   createOneCharMatcher('*', MUL),
-			DIV:   createOneCharMatcher('/', DIV),
-			POW:   createOneCharMatcher('^', POW),
+			OP: func(l *lexer.Lexer) (token lexer.Token, found bool) {
+				for _, symbol := range operatorSymbols {
+					if l.ReadString(symbol) {
+						return Token{Type: OP, Value: symbol}, true
+					}
+				}
+				return Token{}, false
+			},
 			L_PAR: createOneCharMatcher('(', L_PAR),
 			R_PAR: createOneCharMatcher(')', R_PAR),
+			COMMA: createOneCharMatcher(',', COMMA),
 			NUM: func(l *lexer.Lexer) (token lexer.Token, found bool) {
-				val, ok := l.ReadIntOrFloat()
+				val, err := l.ReadNumber()
+				if val == "" {
+					return Token{}, false
+				}
+				// malformed numbers are reported during the validation, with their positions
+				return Token{Type: NUM, Value: val, Err: err}, true
+			},
+			IDENT: func(l *lexer.Lexer) (token lexer.Token, found bool) {
+				val, ok := l.ReadIdentifier()
 				if !ok {
 					return Token{}, false
 				}
-				return Token{NUM, val}, true
+				return Token{Type: IDENT, Value: val}, true
 			},
 			SPACE: func(l *lexer.Lexer) (token lexer.Token, found bool) {
 				val, ok := l.ReadUntil(spaces)
 				if !ok {
 					return Token{}, false
 				}
-				return Token{SPACE, val}, true
+				return Token{Type: SPACE, Value: val}, true
 			},
 		},
 	})
@@ -203,56 +444,216 @@ var ErrInconsistentParacentesisCount = errors.New("inconsistent paracentesis cou
 var Err2Operators = errors.New("cannot have 2 operators side by side")
 var ErrOperationAfterLeftParacantesis = errors.New("cannot have an operation after an opening-paracentesis")
 var ErrCannotStartWithOperator = errors.New("expression cannot start with an operator")
+var ErrCannotEndWithOperator = errors.New("expression cannot end with an operator")
+var Err2Operands = errors.New("cannot have 2 operands side by side")
+var ErrUnknownVariable = errors.New("unknown variable")
+var ErrEmptyParacentheses = errors.New("cannot have empty paracentheses")
+var ErrCommaOutsideFunction = errors.New("cannot have a comma outside of a function call")
+var ErrOperationBeforeComma = errors.New("cannot have an operation before a comma")
+var ErrOperationAfterComma = errors.New("cannot have an operation after a comma")
+var ErrEmptyArgument = errors.New("cannot have an empty function argument")
+var ErrUnknownFunction = errors.New("unknown function")
+var ErrWrongArgumentCount = errors.New("wrong number of arguments")
+var ErrNotBinaryOperator = errors.New("operator cannot be used between 2 operands")
+var ErrDivisionByZero = errors.New("division by zero")
+var ErrNotANumber = errors.New("result is not a number")
+var ErrOverflow = errors.New("result is too large")
+var ErrEmptyExpression = errors.New("expression is empty")
 
-// validateExpression checks if expression is valid. returns the invalid index of the Token
-// -1 means that, even though there was an error, position cannot be found
-func validateExpression(tokens []Token) (int, error) {
-	openParCount := 0
-	prev := Token{}
+// resolveFunctionCalls marks IDENT tokens that are followed by an opening-paracentesis as FUNC.
+// case: "sqrt(4)", "max (1, 2)"
+func resolveFunctionCalls(tokens []Token) {
 	for i, token := range tokens {
-		if token.IsLeftParacentesis() {
-			openParCount++
+		if token.IsLeftParacentesis() && i > 0 && tokens[i-1].IsIdent() {
+			tokens[i-1].Type = FUNC
 		}
-		// case: "..1+)"
-		if token.IsRightParacentesis() && prev.IsOP() {
-			return i, ErrOperationBeforeRightParacentesis
+	}
+}
+
+// resolveUnaryOperators marks OP tokens that don't have a left operand as UNARY_OP, if they are defined as prefix operators.
+// case: "-5", "2*-3", "(+4)"
+// The built-in prefix "-" of the number 9223372036854775808 is merged with it into the number -9223372036854775808,
+// which is math.MinInt64 in EvalInt, even though 9223372036854775808 alone is too large for int64
+func (c Calculator) resolveUnaryOperators(tokens []Token) []Token {
+	resolved := tokens[:0]
+	prev := Token{}
+	for i := 0; i < len(tokens); i++ {
+		token := tokens[i]
+		hasLeftOperand := prev.IsNum() || prev.IsIdent() || prev.IsRightParacentesis()
+		if _, ok := c.prefixOperators[token.Value]; ok && !hasLeftOperand && token.IsOP() {
+			token.Type = UNARY_OP
+			if c.negatesMinInt64(tokens, i) {
+				number := tokens[i+1]
+				token = Token{Type: NUM, Value: "-" + number.Value, StartPos: token.StartPos, EndPos: number.EndPos}
+				i++
+			}
 		}
-		// case: "(3))"
-		if token.IsRightParacentesis() && openParCount == 0 {
-			return i, ErrInconsistentParacentesisCount
+		resolved = append(resolved, token)
+		prev = token
+	}
+	return resolved
+}
+
+// negatesMinInt64 checks if the prefix operator in the given index is the built-in "-" of the number 9223372036854775808,
+// and the number is not the left operand of an operator that binds tighter than "-", like in "-9223372036854775808^2"
+func (c Calculator) negatesMinInt64(tokens []Token, i int) bool {
+	neg := c.prefixOperators["-"]
+	if tokens[i].Value != "-" || neg.node == nil || i+1 == len(tokens) {
+		return false
+	}
+	if number := tokens[i+1]; !number.IsNum() || number.Err != nil || !isMinInt64Magnitude(number.Value) {
+		return false
+	}
+	if i+2 == len(tokens) {
+		return true
+	}
+	next := tokens[i+2]
+	// case: "-9223372036854775808(2)", where the implicit "*" is calculated before "-"
+	if next.IsLeftParacentesis() {
+		return false
+	}
+	op, ok := c.binaryOperators[next.Value]
+	return !next.IsOP() || !ok || shouldPopBefore(neg, op)
+}
+
+// tokenError is a validation error of the Tokens from tokenPos to endTokenPos, which is the same index for the errors of a single Token.
+// -1 means that, even though there was an error, position cannot be found
+type tokenError struct {
+	tokenPos    int
+	endTokenPos int
+	err         error
+}
+
+// validateExpression checks if expression is valid. returns all the errors with invalid indexes of the Tokens
+func (c Calculator) validateExpression(tokens []Token) []tokenError {
+	var errs []tokenError
+	// callErrs are the unknown functions and the wrong number of arguments,
+	// which are reported after the errors of the Tokens, like they are found after parsing
+	var callErrs []tokenError
+	// parens holds the open paracentheses, true if the paracentesis belongs to a function call
+	var parens []bool
+	// calls holds the open function calls, with the index of the FUNC token and the number of commas
+	type funcCall struct {
+		tokenPos int
+		commas   int
+	}
+	var calls []funcCall
+	prev := Token{}
+	prevPos := -1
+	for i, token := range tokens {
+		if err := c.validateToken(token, prev, prevPos == -1, parens); err != nil {
+			errs = append(errs, tokenError{i, i, err})
 		}
-		if token.IsRightParacentesis() {
-			openParCount--
+		if _, ok := c.functions[token.Value]; token.IsFunc() && !ok {
+			callErrs = append(callErrs, tokenError{i, i, ErrUnknownFunction})
 		}
-
-		// case: "3/*4"
-		if token.IsOP() && prev.IsOP() {
-			return i, Err2Operators
+		if token.IsLeftParacentesis() {
+			parens = append(parens, prev.IsFunc())
+			if prev.IsFunc() {
+				calls = append(calls, funcCall{prevPos, 0})
+			}
 		}
-		// case: "3(+"
-		if token.IsOP() && prev.IsLeftParacentesis() {
-			return i, ErrOperationAfterLeftParacantesis
+		if token.IsComma() && len(parens) != 0 && parens[len(parens)-1] {
+			calls[len(calls)-1].commas++
 		}
-		// case: "*5"
-		if token.IsOP() && i == 0 {
-			return i, ErrCannotStartWithOperator
+		if token.IsRightParacentesis() && len(parens) != 0 {
+			if parens[len(parens)-1] {
+				call := calls[len(calls)-1]
+				calls = calls[:len(calls)-1]
+				argCount := call.commas + 1
+				// case: "f()"
+				if prev.IsLeftParacentesis() {
+					argCount = 0
+				}
+				if fn, ok := c.functions[tokens[call.tokenPos].Value]; ok && !fn.acceptsArgs(argCount) {
+					callErrs = append(callErrs, tokenError{call.tokenPos, i, ErrWrongArgumentCount})
+				}
+			}
+			parens = parens[:len(parens)-1]
 		}
-
 		prev = token
+		prevPos = i
 	}
 
+	// case: "", "   "
+	if prevPos == -1 {
+		return []tokenError{{-1, -1, ErrEmptyExpression}}
+	}
+	// case: "5+", "-"
+	if prev.IsOP() || prev.IsUnaryOP() {
+		errs = append(errs, tokenError{prevPos, prevPos, ErrCannotEndWithOperator})
+	}
 	// (5+4
-	if openParCount != 0 {
-		return -1, ErrInconsistentParacentesisCount
+	if len(parens) != 0 {
+		errs = append(errs, tokenError{-1, -1, ErrInconsistentParacentesisCount})
 	}
-	return 0, nil
+	return append(errs, callErrs...)
 }
 
-// findTokenPositionInRawInput find and returns the position of the Token in the Raw input
-func findTokenPositionInRawInput(tokens []Token, tokenPos int) (startPos, endPos int) {
-	for i := 0; i < tokenPos; i++ {
-		startPos += len(tokens[i].Value)
+// validateToken checks if the token can come after the `prev` token. returns the first problem it finds.
+// parens holds the paracentheses that are open before the token
+func (c Calculator) validateToken(token, prev Token, isFirst bool, parens []bool) error {
+	// case: "1e", "0x", "1__0"
+	if token.IsNum() && token.Err != nil {
+		return token.Err
+	}
+	// case: "..1+)", "(-)"
+	if token.IsRightParacentesis() && (prev.IsOP() || prev.IsUnaryOP()) {
+		return ErrOperationBeforeRightParacentesis
+	}
+	// case: "(3))"
+	if token.IsRightParacentesis() && len(parens) == 0 {
+		return ErrInconsistentParacentesisCount
+	}
+	// case: "2*()", function calls can be empty: "f()"
+	if token.IsRightParacentesis() && prev.IsLeftParacentesis() && !parens[len(parens)-1] {
+		return ErrEmptyParacentheses
+	}
+	// case: "max(1,)"
+	if token.IsRightParacentesis() && prev.IsComma() {
+		return ErrEmptyArgument
+	}
+	// case: "1,2", "(1,2)"
+	if token.IsComma() && (len(parens) == 0 || !parens[len(parens)-1]) {
+		return ErrCommaOutsideFunction
+	}
+	// case: "max(,1)", "max(1,,2)"
+	if token.IsComma() && (prev.IsLeftParacentesis() || prev.IsComma()) {
+		return ErrEmptyArgument
+	}
+	// case: "max(1+,2)"
+	if token.IsComma() && (prev.IsOP() || prev.IsUnaryOP()) {
+		return ErrOperationBeforeComma
+	}
+
+	// case: "2 3", "x y", "(2)3", "2 sin(x)"
+	if (token.IsNum() || token.IsIdent() || token.IsFunc()) && (prev.IsNum() || prev.IsIdent() || prev.IsRightParacentesis()) {
+		return Err2Operands
+	}
+	// case: "(2)(3)"
+	if token.IsLeftParacentesis() && prev.IsRightParacentesis() {
+		return Err2Operands
+	}
+
+	// case: "3/*4", "-*4"
+	if token.IsOP() && (prev.IsOP() || prev.IsUnaryOP()) {
+		return Err2Operators
+	}
+	// case: "3(*"
+	if token.IsOP() && prev.IsLeftParacentesis() {
+		return ErrOperationAfterLeftParacantesis
+	}
+	// case: "max(1,*2)"
+	if token.IsOP() && prev.IsComma() {
+		return ErrOperationAfterComma
+	}
+	// case: "*5"
+	if token.IsOP() && isFirst {
+		return ErrCannotStartWithOperator
+	}
+	// case: "2 ! 3", where "!" is only a prefix operator
+	if _, ok := c.binaryOperators[token.Value]; token.IsOP() && !ok {
+		return ErrNotBinaryOperator
 	}
-	endPos = startPos + len(tokens[tokenPos].Value)
-	return startPos, endPos
+	return nil
 }
@@ -2,6 +2,10 @@ package calculator_test
 
 import (
 	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"sync"
 	"testing"
 
 	calculator "github.com/DavudSafarli/design-calculator-challenge"
@@ -38,6 +42,34 @@ func TestValidExpressions(t *testing.T) {
 		{"2.5(4.0+2)", 15},
 		{"2.5((4.0+2))", 15},
 		{"2.5+((4.0+2))", 8.5},
+		{"-5+3", -2},
+		{"+5+3", 8},
+		{"2*-3", -6},
+		{"2 * - 3", -6},
+		{"2--3", 5},
+		{"2+-3", -1},
+		{"--2", 2},
+		{"-+-2", 2},
+		{"-2^2", -4},
+		{"(-2)^2", 4},
+		{"2^-1", 0.5},
+		{"-2*3", -6},
+		{"-(2+3)*2", -10},
+		{"(-5+3)", -2},
+		{"2(-3)", -6},
+		{"2^3^2", 512},
+		{"(2^3)^2", 64},
+		{"2^3^2*2", 1024},
+		{"2*3^2^2", 162},
+		{"2^3^2-2^2^3", 256},
+		{"-2^2^3", -256},
+		{"2^-1^2", 0.5},
+		{"2^3+2^2^0", 10},
+		{"4^3^2/4^8", 4},
+		{"2^(1+1)^3", 256},
+		{"1-2-3", -4},
+		{"8/4/2", 1},
+		{"8/4*2", 4},
 	}
 
 	for _, tt := range tests {
@@ -70,6 +102,18 @@ func TestInvalidExpressions(t *testing.T) {
 		{"*5+4", calculator.EvalError{calculator.ErrCannotStartWithOperator, 0, 1}},
 		{"(5", calculator.EvalError{calculator.ErrInconsistentParacentesisCount, -1, -1}},
 		{"  1.2+*", calculator.EvalError{calculator.Err2Operators, 6, 7}},
+		{"1+ *2", calculator.EvalError{calculator.Err2Operators, 3, 4}},
+		{"-*2", calculator.EvalError{calculator.Err2Operators, 1, 2}},
+		{"(-)", calculator.EvalError{calculator.ErrOperationBeforeRightParacentesis, 2, 3}},
+		{"5+", calculator.EvalError{calculator.ErrCannotEndWithOperator, 1, 2}},
+		{"-", calculator.EvalError{calculator.ErrCannotEndWithOperator, 0, 1}},
+		{"2*- ", calculator.EvalError{calculator.ErrCannotEndWithOperator, 2, 3}},
+		{"2 3", calculator.EvalError{calculator.Err2Operands, 2, 3}},
+		{"(2)3", calculator.EvalError{calculator.Err2Operands, 3, 4}},
+		{"(2)(3)", calculator.EvalError{calculator.Err2Operands, 3, 4}},
+		{"1 + x", calculator.EvalError{calculator.ErrUnknownVariable, 4, 5}},
+		{"", calculator.EvalError{calculator.ErrEmptyExpression, -1, -1}},
+		{"   ", calculator.EvalError{calculator.ErrEmptyExpression, -1, -1}},
 	}
 
 	for _, tt := range tests {
@@ -84,12 +128,543 @@ func TestInvalidExpressions(t *testing.T) {
 	}
 }
 
+func TestEvalWithVariables(t *testing.T) {
+	vars := map[string]float64{"price": 10, "qty": 3, "tax": 0.2, "x": 2, "_y1": 5}
+	tests := []struct {
+		input string
+		want  float64
+		err   error
+	}{
+		{"price * qty * (1 + tax)", 36, nil},
+		{"x^2 + 2x", 0, calculator.EvalError{calculator.Err2Operands, 7, 8}},
+		{"-x^2", -4, nil},
+		{"_y1 - x", 3, nil},
+		{"2(x + 1)", 6, nil},
+		{"price * discount", 0, calculator.EvalError{calculator.ErrUnknownVariable, 8, 16}},
+		{"x y", 0, calculator.EvalError{calculator.Err2Operands, 2, 3}},
+		{"x(1)", 0, calculator.EvalError{calculator.ErrUnknownFunction, 0, 1}},
+		{"max(price, qty) * x", 20, nil},
+	}
+
+	for _, tt := range tests {
+		testName := fmt.Sprint("Calculating ", tt.input)
+		t.Run(testName, func(t *testing.T) {
+			c := calculator.New()
+			actual, evalErr := c.EvalWith(tt.input, vars)
+			if evalErr != tt.err {
+				t.Fatalf("\nexpected: %v\nactual  : %v", tt.err, evalErr)
+			}
+			if actual != tt.want {
+				t.Fatalf("\nexpected: %v\nactual  : %v", tt.want, actual)
+			}
+		})
+	}
+}
+
+func TestFunctionCalls(t *testing.T) {
+	tests := []struct {
+		input string
+		want  float64
+		err   error
+	}{
+		{"sqrt(16)", 4, nil},
+		{"sqrt (16) + 1", 5, nil},
+		{"abs(-3)", 3, nil},
+		{"-abs(3)^2", -9, nil},
+		{"max(1, 5, 3)", 5, nil},
+		{"min(4)", 4, nil},
+		{"min(4, max(1, 2), 3)", 2, nil},
+		{"pow(2, 3) * 2", 16, nil},
+		{"2(floor(2.7) + ceil(0.2))", 6, nil},
+		{"log(exp(2))", 2, nil},
+		{"cos(0) + sin(0)", 1, nil},
+		{"round(2.5) - trunc(-2.5)", 5, nil},
+		{"foo(1)", 0, calculator.EvalError{calculator.ErrUnknownFunction, 0, 3}},
+		{"1 + sqrt(1, 2)", 0, calculator.EvalError{calculator.ErrWrongArgumentCount, 4, 14}},
+		{"max()", 0, calculator.EvalError{calculator.ErrWrongArgumentCount, 0, 5}},
+		{"pow(2)", 0, calculator.EvalError{calculator.ErrWrongArgumentCount, 0, 6}},
+		{"max(1,)", 0, calculator.EvalError{calculator.ErrEmptyArgument, 6, 7}},
+		{"max(,1)", 0, calculator.EvalError{calculator.ErrEmptyArgument, 4, 5}},
+		{"max(1,,2)", 0, calculator.EvalError{calculator.ErrEmptyArgument, 6, 7}},
+		{"max(1+,2)", 0, calculator.EvalError{calculator.ErrOperationBeforeComma, 6, 7}},
+		{"max(1,*2)", 0, calculator.EvalError{calculator.ErrOperationAfterComma, 6, 7}},
+		{"1,2", 0, calculator.EvalError{calculator.ErrCommaOutsideFunction, 1, 2}},
+		{"max((1,2))", 0, calculator.EvalError{calculator.ErrCommaOutsideFunction, 6, 7}},
+		{"2*()", 0, calculator.EvalError{calculator.ErrEmptyParacentheses, 3, 4}},
+		{"2 sqrt(4)", 0, calculator.EvalError{calculator.Err2Operands, 2, 6}},
+	}
+
+	for _, tt := range tests {
+		testName := fmt.Sprint("Calculating ", tt.input)
+		t.Run(testName, func(t *testing.T) {
+			c := calculator.New()
+			actual, evalErr := c.Eval(tt.input)
+			if evalErr != tt.err {
+				t.Fatalf("\nexpected: %v\nactual  : %v", tt.err, evalErr)
+			}
+			if actual != tt.want {
+				t.Fatalf("\nexpected: %v\nactual  : %v", tt.want, actual)
+			}
+		})
+	}
+}
+
+func TestCustomFunctions(t *testing.T) {
+	discount := calculator.Function{MinArgs: 2, MaxArgs: 2, Fn: func(args ...float64) float64 {
+		return args[0] * (1 - args[1]/100)
+	}}
+	clamp := calculator.Function{MinArgs: 3, MaxArgs: 3, Fn: func(args ...float64) float64 {
+		return math.Max(args[1], math.Min(args[0], args[2]))
+	}}
+	sum := calculator.Function{MinArgs: 0, MaxArgs: -1, Fn: func(args ...float64) float64 {
+		res := 0.0
+		for _, v := range args {
+			res += v
+		}
+		return res
+	}}
+	c := calculator.New(
+		calculator.WithFunction("discount", discount),
+		calculator.WithFunction("clamp", clamp),
+		calculator.WithFunction("sum", sum),
+		calculator.WithFunction("abs", calculator.Function{1, 1, func(args ...float64) float64 { return 42 }}),
+	)
+
+	tests := []struct {
+		input string
+		want  float64
+		err   error
+	}{
+		{"discount(200, 15)", 170, nil},
+		{"clamp(15, 0, 10) + clamp(-5, 0, 10)", 10, nil},
+		{"sum()", 0, nil},
+		{"sum(1, 2, 3, 4)", 10, nil},
+		{"sqrt(sum(9, 7))", 4, nil},
+		{"abs(-1)", 42, nil},
+		{"clamp(1, 2)", 0, calculator.EvalError{calculator.ErrWrongArgumentCount, 0, 11}},
+	}
+
+	for _, tt := range tests {
+		testName := fmt.Sprint("Calculating ", tt.input)
+		t.Run(testName, func(t *testing.T) {
+			actual, evalErr := c.Eval(tt.input)
+			if evalErr != tt.err {
+				t.Fatalf("\nexpected: %v\nactual  : %v", tt.err, evalErr)
+			}
+			if actual != tt.want {
+				t.Fatalf("\nexpected: %v\nactual  : %v", tt.want, actual)
+			}
+		})
+	}
+
+	t.Run("functions are registered per Calculator", func(t *testing.T) {
+		_, err := calculator.New().Eval("discount(200, 15)")
+		expected := calculator.EvalError{calculator.ErrUnknownFunction, 0, 8}
+		if err != expected {
+			t.Fatalf("\nexpected: %v\nactual  : %v", expected, err)
+		}
+		res, _ := calculator.New().Eval("abs(-1)")
+		if res != 1 {
+			t.Fatalf("\nexpected: %v\nactual  : %v", 1, res)
+		}
+	})
+
+	t.Run("invalid functions", func(t *testing.T) {
+		invalid := []struct {
+			name string
+			fn   calculator.Function
+		}{
+			{"", sum},
+			{"1x", sum},
+			{"a b", sum},
+			{"f(", sum},
+			{"nofn", calculator.Function{MinArgs: 1, MaxArgs: 1}},
+			{"negative", calculator.Function{MinArgs: -1, MaxArgs: 1, Fn: sum.Fn}},
+			{"reversed", calculator.Function{MinArgs: 3, MaxArgs: 2, Fn: sum.Fn}},
+		}
+		for _, tt := range invalid {
+			func() {
+				defer func() {
+					if recover() == nil {
+						t.Errorf("expected panic for %q", tt.name)
+					}
+				}()
+				calculator.New(calculator.WithFunction(tt.name, tt.fn))
+			}()
+		}
+	})
+}
+
+func TestCompile(t *testing.T) {
+	c := calculator.New()
+	expr, err := c.Compile("price * qty * (1 + tax)")
+	if err != nil {
+		t.Fatalf("\nexpected: nil\nactual  : %v", err)
+	}
+
+	tests := []struct {
+		vars map[string]float64
+		want float64
+		err  error
+	}{
+		{map[string]float64{"price": 10, "qty": 3, "tax": 0.2}, 36, nil},
+		{map[string]float64{"price": 5, "qty": 2, "tax": 0}, 10, nil},
+		{map[string]float64{"price": 5, "tax": 0}, 0, calculator.EvalError{calculator.ErrUnknownVariable, 8, 11}},
+	}
+	for _, tt := range tests {
+		testName := fmt.Sprint("Calculating with ", tt.vars)
+		t.Run(testName, func(t *testing.T) {
+			actual, evalErr := expr.Eval(tt.vars)
+			if evalErr != tt.err {
+				t.Fatalf("\nexpected: %v\nactual  : %v", tt.err, evalErr)
+			}
+			if actual != tt.want {
+				t.Fatalf("\nexpected: %v\nactual  : %v", tt.want, actual)
+			}
+		})
+	}
+
+	t.Run("invalid expression", func(t *testing.T) {
+		tests := []struct {
+			input string
+			err   error
+		}{
+			{"1+*2", calculator.EvalError{calculator.Err2Operators, 2, 3}},
+			{"", calculator.EvalError{calculator.ErrEmptyExpression, -1, -1}},
+			{"   ", calculator.EvalError{calculator.ErrEmptyExpression, -1, -1}},
+		}
+		for _, tt := range tests {
+			expr, err := c.Compile(tt.input)
+			if err != tt.err || expr != nil {
+				t.Fatalf("\nexpected: <nil>, %v\nactual  : %v, %v", tt.err, expr, err)
+			}
+		}
+	})
+
+	t.Run("concurrent evaluation", func(t *testing.T) {
+		var wg sync.WaitGroup
+		for i := 0; i < 50; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				qty := float64(i)
+				actual, err := expr.Eval(map[string]float64{"price": 2, "qty": qty, "tax": 0.5})
+				if err != nil || actual != 3*qty {
+					t.Errorf("\nexpected: %v\nactual  : %v, %v", 3*qty, actual, err)
+				}
+			}(i)
+		}
+		wg.Wait()
+	})
+}
+
+func TestCustomOperators(t *testing.T) {
+	c := calculator.New(calculator.WithOperators(
+		calculator.OperatorSpec{Symbol: "%", Precedence: 2, Associativity: calculator.LeftAssociative, Arity: 2, Fn: func(args ...float64) float64 {
+			return math.Mod(args[0], args[1])
+		}},
+		calculator.OperatorSpec{Symbol: "//", Precedence: 2, Associativity: calculator.LeftAssociative, Arity: 2, Fn: func(args ...float64) float64 {
+			return math.Floor(args[0] / args[1])
+		}},
+		calculator.OperatorSpec{Symbol: "**", Precedence: 4, Associativity: calculator.RightAssociative, Arity: 2, Fn: pow},
+		calculator.OperatorSpec{Symbol: "√", Precedence: 3, Associativity: calculator.RightAssociative, Arity: 1, Fn: func(args ...float64) float64 {
+			return math.Sqrt(args[0])
+		}},
+	))
+
+	tests := []struct {
+		input string
+		want  float64
+		err   error
+	}{
+		{"7 % 4", 3, nil},
+		{"1 + 7 % 4 * 2", 7, nil},
+		{"7 // 2", 3, nil},
+		{"7 / 2", 3.5, nil},
+		{"2 ** 3 ** 2", 512, nil},
+		{"2 ** 3 * 2", 16, nil},
+		{"2*√16", 8, nil},
+		{"√(9+7) - -1", 5, nil},
+		{"2 √ 4", 0, calculator.EvalError{calculator.ErrNotBinaryOperator, 2, 3}},
+		{"√√16 √ 4", 0, calculator.EvalError{calculator.ErrNotBinaryOperator, 5, 6}},
+		{"7 %% 4", 0, calculator.EvalError{calculator.Err2Operators, 3, 4}},
+		{"% 4", 0, calculator.EvalError{calculator.ErrCannotStartWithOperator, 0, 1}},
+	}
+
+	for _, tt := range tests {
+		testName := fmt.Sprint("Calculating ", tt.input)
+		t.Run(testName, func(t *testing.T) {
+			actual, evalErr := c.Eval(tt.input)
+			if evalErr != tt.err {
+				t.Fatalf("\nexpected: %v\nactual  : %v", tt.err, evalErr)
+			}
+			if actual != tt.want {
+				t.Fatalf("\nexpected: %v\nactual  : %v", tt.want, actual)
+			}
+		})
+	}
+
+	t.Run("operators are registered per Calculator", func(t *testing.T) {
+		_, err := calculator.New().Eval("7 % 4")
+		expected := lexer.UnknownSymbolError{Symbol: '%', Pos: lexer.Position{Offset: 2, Line: 1, Column: 3}}
+		if err != expected {
+			t.Fatalf("\nexpected: %v\nactual  : %v", expected, err)
+		}
+	})
+
+	t.Run("invalid operators", func(t *testing.T) {
+		invalid := []calculator.OperatorSpec{
+			{Symbol: "", Arity: 2, Fn: pow},
+			{Symbol: "x", Arity: 2, Fn: pow},
+			{Symbol: "(", Arity: 2, Fn: pow},
+			{Symbol: "@", Arity: 3, Fn: pow},
+			{Symbol: "@", Arity: 2},
+		}
+		for _, spec := range invalid {
+			func() {
+				defer func() {
+					if recover() == nil {
+						t.Errorf("expected panic for %q", spec.Symbol)
+					}
+				}()
+				calculator.New(calculator.WithOperators(spec))
+			}()
+		}
+	})
+}
+
+func TestStrictMode(t *testing.T) {
+	c := calculator.New(calculator.WithStrictMode())
+	vars := map[string]float64{"zero": 0, "inf": math.Inf(1)}
+
+	tests := []struct {
+		input string
+		want  float64
+		err   error
+	}{
+		{"1 + 6/3", 3, nil},
+		{"-2^2", -4, nil},
+		{"sqrt(16)", 4, nil},
+		{"1 + 1/0", 0, calculator.EvalError{calculator.ErrDivisionByZero, 5, 6}},
+		{"2 * (5 / zero)", 0, calculator.EvalError{calculator.ErrDivisionByZero, 7, 8}},
+		{"0/0", 0, calculator.EvalError{calculator.ErrNotANumber, 1, 2}},
+		{"(0-8)^0.5", 0, calculator.EvalError{calculator.ErrNotANumber, 5, 6}},
+		{"0^-1", 0, calculator.EvalError{calculator.ErrDivisionByZero, 1, 2}},
+		{"10^400", 0, calculator.EvalError{calculator.ErrOverflow, 2, 3}},
+		{"1 + exp(1000)", 0, calculator.EvalError{calculator.ErrOverflow, 4, 13}},
+		{"sqrt(-1)", 0, calculator.EvalError{calculator.ErrNotANumber, 0, 8}},
+		{"inf - 1", 0, calculator.EvalError{calculator.ErrOverflow, 4, 5}},
+		{"2(1/0)", 0, calculator.EvalError{calculator.ErrDivisionByZero, 3, 4}},
+		{"1 + log(0)", 0, calculator.EvalError{calculator.ErrOverflow, 4, 10}},
+		{"1e308 / 0.1", 0, calculator.EvalError{calculator.ErrOverflow, 6, 7}},
+		{"zero * inf", 0, calculator.EvalError{calculator.ErrNotANumber, 5, 6}},
+		{"zero * 1e999", 0, calculator.EvalError{calculator.ErrOverflow, 7, 12}},
+		{"1e400", 0, calculator.EvalError{calculator.ErrOverflow, 0, 5}},
+		{"-1e309 + 1", 0, calculator.EvalError{calculator.ErrOverflow, 1, 6}},
+		{"+inf", 0, calculator.EvalError{calculator.ErrOverflow, 0, 1}},
+	}
+
+	for _, tt := range tests {
+		testName := fmt.Sprint("Calculating ", tt.input)
+		t.Run(testName, func(t *testing.T) {
+			actual, evalErr := c.EvalWith(tt.input, vars)
+			if evalErr != tt.err {
+				t.Fatalf("\nexpected: %v\nactual  : %v", tt.err, evalErr)
+			}
+			if actual != tt.want {
+				t.Fatalf("\nexpected: %v\nactual  : %v", tt.want, actual)
+			}
+		})
+	}
+
+	t.Run("strict mode is opt-in", func(t *testing.T) {
+		actual, err := calculator.New().Eval("1/0")
+		if err != nil || !math.IsInf(actual, 1) {
+			t.Fatalf("\nexpected: +Inf, <nil>\nactual  : %v, %v", actual, err)
+		}
+	})
+}
+
+func TestAllErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		err   error
+	}{
+		{"1+*2", calculator.EvalErrors{{calculator.Err2Operators, 2, 3}}},
+		{"(*5+)) 2 3", calculator.EvalErrors{
+			{calculator.ErrOperationAfterLeftParacantesis, 1, 2},
+			{calculator.ErrOperationBeforeRightParacentesis, 4, 5},
+			{calculator.ErrInconsistentParacentesisCount, 5, 6},
+			{calculator.Err2Operands, 7, 8},
+			{calculator.Err2Operands, 9, 10},
+		}},
+		{"max(1,,2) + (3", calculator.EvalErrors{
+			{calculator.ErrEmptyArgument, 6, 7},
+			{calculator.ErrInconsistentParacentesisCount, -1, -1},
+		}},
+		{"*1 + 2 /", calculator.EvalErrors{
+			{calculator.ErrCannotStartWithOperator, 0, 1},
+			{calculator.ErrCannotEndWithOperator, 7, 8},
+		}},
+		{"foo(1) + bar(2)", calculator.EvalErrors{
+			{calculator.ErrUnknownFunction, 0, 3},
+			{calculator.ErrUnknownFunction, 9, 12},
+		}},
+		{"pow(1) * max() + foo(max(1, 2), 3)", calculator.EvalErrors{
+			{calculator.ErrWrongArgumentCount, 0, 6},
+			{calculator.ErrWrongArgumentCount, 9, 14},
+			{calculator.ErrUnknownFunction, 17, 20},
+		}},
+		{"foo(1) + *2", calculator.EvalErrors{
+			{calculator.Err2Operators, 9, 10},
+			{calculator.ErrUnknownFunction, 0, 3},
+		}},
+	}
+
+	for _, tt := range tests {
+		testName := fmt.Sprint("Calculating ", tt.input)
+		t.Run(testName, func(t *testing.T) {
+			c := calculator.New(calculator.WithAllErrors())
+			_, evalErr := c.Eval(tt.input)
+			if !reflect.DeepEqual(evalErr, tt.err) {
+				t.Fatalf("\nexpected: %v\nactual  : %v", tt.err, evalErr)
+			}
+		})
+	}
+
+	t.Run("valid expression", func(t *testing.T) {
+		c := calculator.New(calculator.WithAllErrors())
+		actual, err := c.Eval("1+2")
+		if err != nil || actual != 3 {
+			t.Fatalf("\nexpected: 3, <nil>\nactual  : %v, %v", actual, err)
+		}
+	})
+
+	t.Run("first error is reported by default", func(t *testing.T) {
+		_, err := calculator.New().Eval("(*5+)) 2 3")
+		expected := calculator.EvalError{calculator.ErrOperationAfterLeftParacantesis, 1, 2}
+		if err != expected {
+			t.Fatalf("\nexpected: %v\nactual  : %v", expected, err)
+		}
+	})
+}
+
+func TestErrorPositions(t *testing.T) {
+	c := calculator.New(calculator.WithOperators(
+		calculator.OperatorSpec{Symbol: "√", Precedence: 3, Associativity: calculator.RightAssociative, Arity: 1, Fn: func(args ...float64) float64 {
+			return math.Sqrt(args[0])
+		}},
+	))
+	type position = calculator.Position
+	tests := []struct {
+		input      string
+		err        error
+		start, end position
+	}{
+		{"1+*2", calculator.EvalError{calculator.Err2Operators, 2, 3}, position{2, 2, 2, 1, 3}, position{3, 3, 3, 1, 4}},
+		{"√4 + çay", calculator.EvalError{calculator.ErrUnknownVariable, 5, 8}, position{7, 5, 5, 1, 6}, position{11, 8, 8, 1, 9}},
+		{"𝑥 + (1 +)", calculator.EvalError{calculator.ErrOperationBeforeRightParacentesis, 8, 9}, position{11, 8, 9, 1, 9}, position{12, 9, 10, 1, 10}},
+		{"1 +\n2 *\n  * 3", calculator.EvalError{calculator.Err2Operators, 10, 11}, position{10, 10, 10, 3, 3}, position{11, 11, 11, 3, 4}},
+		{"(1", calculator.EvalError{calculator.ErrInconsistentParacentesisCount, -1, -1}, position{-1, -1, -1, -1, -1}, position{-1, -1, -1, -1, -1}},
+	}
+
+	for _, tt := range tests {
+		testName := fmt.Sprint("Calculating ", tt.input)
+		t.Run(testName, func(t *testing.T) {
+			_, err := c.Eval(tt.input)
+			if err != tt.err {
+				t.Fatalf("\nexpected: %v\nactual  : %v", tt.err, err)
+			}
+			start, end := err.(calculator.EvalError).Positions(tt.input)
+			if start != tt.start || end != tt.end {
+				t.Fatalf("\nexpected: %+v %+v\nactual  : %+v %+v", tt.start, tt.end, start, end)
+			}
+		})
+	}
+
+	t.Run("another input", func(t *testing.T) {
+		err := calculator.EvalError{calculator.ErrUnknownVariable, 5, 8}
+		unknown := position{-1, -1, -1, -1, -1}
+		// the error doesn't fit in the shorter input
+		start, end := err.Positions("√4 + ç")
+		if start != unknown || end != unknown {
+			t.Fatalf("\nexpected: %+v %+v\nactual  : %+v %+v", unknown, unknown, start, end)
+		}
+		// the positions are counted in the given input, even if it is not the evaluated one
+		start, end = err.Positions("1 + teas")
+		expectedStart, expectedEnd := position{5, 5, 5, 1, 6}, position{8, 8, 8, 1, 9}
+		if start != expectedStart || end != expectedEnd {
+			t.Fatalf("\nexpected: %+v %+v\nactual  : %+v %+v", expectedStart, expectedEnd, start, end)
+		}
+	})
+
+	t.Run("invalid UTF-8", func(t *testing.T) {
+		err := calculator.EvalError{calculator.ErrUnknownVariable, 0, 2}
+		start, end := err.Positions("a\xffb")
+		expectedStart, expectedEnd := position{0, 0, 0, 1, 1}, position{2, 2, 2, 1, 3}
+		if start != expectedStart || end != expectedEnd {
+			t.Fatalf("\nexpected: %+v %+v\nactual  : %+v %+v", expectedStart, expectedEnd, start, end)
+		}
+	})
+}
+
+func TestNumberLiterals(t *testing.T) {
+	tests := []struct {
+		input string
+		want  float64
+		err   error
+	}{
+		{"1e-3", 0.001, nil},
+		{"2.5e2 + 1E3", 1250, nil},
+		{"1e+2", 100, nil},
+		{".5 + .25", 0.75, nil},
+		{"2.5(.5)", 1.25, nil},
+		{"0xFF", 255, nil},
+		{"0Xff - 0x_0F", 240, nil},
+		{"0b1010", 10, nil},
+		{"0o17", 15, nil},
+		{"-0x10", -16, nil},
+		{"1_000 * 2", 2000, nil},
+		{"1_000.000_1", 1000.0001, nil},
+		{"0x1_0000_0000_0000_0000", 18446744073709551616, nil},
+		{"5.", 5, nil},
+		{"2*5.-1", 9, nil},
+		{"1.e3", 1000, nil},
+		{"2.(3)", 6, nil},
+		{"1e", 0, calculator.EvalError{lexer.ErrExponentHasNoDigits, 0, 2}},
+		{"2 * 1e+", 0, calculator.EvalError{lexer.ErrExponentHasNoDigits, 4, 7}},
+		{"0x", 0, calculator.EvalError{lexer.ErrNumberHasNoDigits, 0, 2}},
+		{"1 + 0b_", 0, calculator.EvalError{lexer.ErrNumberHasNoDigits, 4, 7}},
+		{"0b102", 0, calculator.EvalError{lexer.ErrInvalidDigit, 0, 5}},
+		{"0o8", 0, calculator.EvalError{lexer.ErrInvalidDigit, 0, 3}},
+		{"0xG", 0, calculator.EvalError{lexer.ErrInvalidDigit, 0, 3}},
+		{"1__0", 0, calculator.EvalError{lexer.ErrInvalidDigitSeparator, 0, 4}},
+		{"1_.5", 0, calculator.EvalError{lexer.ErrInvalidDigitSeparator, 0, 4}},
+		{"1e1_", 0, calculator.EvalError{lexer.ErrInvalidDigitSeparator, 0, 4}},
+		{"1._5", 0, calculator.EvalError{lexer.ErrInvalidDigitSeparator, 0, 4}},
+		{"1.e", 0, calculator.EvalError{lexer.ErrExponentHasNoDigits, 0, 3}},
+	}
+
+	for _, tt := range tests {
+		testName := fmt.Sprint("Calculating ", tt.input)
+		t.Run(testName, func(t *testing.T) {
+			actual, evalErr := calculator.New().Eval(tt.input)
+			if evalErr != tt.err {
+				t.Fatalf("\nexpected: %v\nactual  : %v", tt.err, evalErr)
+			}
+			if actual != tt.want {
+				t.Fatalf("\nexpected: %v\nactual  : %v", tt.want, actual)
+			}
+		})
+	}
+}
+
 func TestInvalidInputs(t *testing.T) {
 	tests := []struct {
 		input string
 		err   error
 	}{
-		{"5+&&??", lexer.UnknownSymbolError{Symbol: '&'}},
+		{"5+&&??", lexer.UnknownSymbolError{Symbol: '&', Pos: lexer.Position{Offset: 2, Line: 1, Column: 3}}},
 	}
 
 	for _, tt := range tests {
@@ -103,3 +678,517 @@ func TestInvalidInputs(t *testing.T) {
 		})
 	}
 }
+
+const benchmarkInput = "price * qty * (1 + tax) - max(discount, 2) / 3^2"
+
+func TestExactMode(t *testing.T) {
+	c := calculator.New(calculator.WithOperators(
+		calculator.OperatorSpec{Symbol: "%", Precedence: 2, Associativity: calculator.LeftAssociative, Arity: 2, Fn: func(args ...float64) float64 {
+			return math.Mod(args[0], args[1])
+		}},
+	))
+	vars := map[string]*big.Rat{"price": big.NewRat(1999, 100), "zero": new(big.Rat), "none": nil}
+
+	tests := []struct {
+		input string
+		want  string
+		err   error
+	}{
+		{"0.1+0.2", "3/10", nil},
+		{"1/3 + 1/6", "1/2", nil},
+		{"price * 3", "5997/100", nil},
+		{"-2^2", "-4", nil},
+		{"(2/3)^-2", "9/4", nil},
+		{"(0-1)^1001", "-1", nil},
+		{"0^0", "1", nil},
+		{"1e-3 + .5", "501/1000", nil},
+		{"0xFF + 0b1_0", "257", nil},
+		{"2(1/4)", "1/2", nil},
+		{"1 + 1/zero", "", calculator.EvalError{calculator.ErrDivisionByZero, 5, 6}},
+		{"0^-1", "", calculator.EvalError{calculator.ErrDivisionByZero, 1, 2}},
+		{"2^0.5", "", calculator.EvalError{calculator.ErrNotExact, 1, 2}},
+		{"2^(10^12)", "", calculator.EvalError{calculator.ErrOverflow, 1, 2}},
+		{"1 + sqrt(4)", "", calculator.EvalError{calculator.ErrNotExact, 4, 11}},
+		{"5 % 3", "", calculator.EvalError{calculator.ErrNotExact, 2, 3}},
+		{"1e9999999", "", calculator.EvalError{calculator.ErrOverflow, 0, 9}},
+		{"x + 1", "", calculator.EvalError{calculator.ErrUnknownVariable, 0, 1}},
+		{"1 + none", "", calculator.EvalError{calculator.ErrUnknownVariable, 4, 8}},
+	}
+
+	for _, tt := range tests {
+		testName := fmt.Sprint("Calculating ", tt.input)
+		t.Run(testName, func(t *testing.T) {
+			actual, err := c.EvalRatWith(tt.input, vars)
+			if err != tt.err {
+				t.Fatalf("\nexpected: %v\nactual  : %v", tt.err, err)
+			}
+			if err != nil {
+				return
+			}
+			if actual.Fraction() != tt.want {
+				t.Fatalf("\nexpected: %v\nactual  : %v", tt.want, actual.Fraction())
+			}
+		})
+	}
+
+	t.Run("variables are not modified", func(t *testing.T) {
+		if _, err := c.EvalRatWith("-price", vars); err != nil {
+			t.Fatal(err)
+		}
+		if vars["price"].Cmp(big.NewRat(1999, 100)) != 0 {
+			t.Fatalf("\nexpected: %v\nactual  : %v", "1999/100", vars["price"])
+		}
+	})
+
+	t.Run("renders as fixed decimal", func(t *testing.T) {
+		actual, err := c.EvalRat("2/3")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual.Decimal(2) != "0.67" {
+			t.Fatalf("\nexpected: %v\nactual  : %v", "0.67", actual.Decimal(2))
+		}
+	})
+
+	t.Run("failed result renders as 0", func(t *testing.T) {
+		actual, err := c.EvalRat("1/0")
+		if err == nil {
+			t.Fatal("expected an error")
+		}
+		if fmt.Sprint(actual) != "0/1" || actual.Fraction() != "0" || actual.Sign() != 0 {
+			t.Fatalf("\nexpected: %v\nactual  : %v", "0/1", fmt.Sprint(actual))
+		}
+		var zero calculator.Rational
+		if zero.Decimal(2) != "0.00" || zero.String() != "0/1" {
+			t.Fatalf("\nexpected: %v\nactual  : %v", "0.00", zero.Decimal(2))
+		}
+	})
+}
+
+func TestBigFloatMode(t *testing.T) {
+	c := calculator.New(calculator.WithPrecision(400), calculator.WithFunction("double", calculator.Function{MinArgs: 1, MaxArgs: 1, Fn: func(args ...float64) float64 {
+		return args[0] * 2
+	}}))
+	vars := map[string]*big.Float{"x": big.NewFloat(0.5), "none": nil}
+
+	tests := []struct {
+		input string
+		// rounded to 60 digits after the decimal point
+		want string
+		err  error
+	}{
+		{"4*atan(1)", "3.141592653589793238462643383279502884197169399375105820974945", nil},
+		{"exp(1)", "2.718281828459045235360287471352662497757247093699959574966968", nil},
+		{"sqrt(2)", "1.414213562373095048801688724209698078569671875376948073176680", nil},
+		{"2^0.5", "1.414213562373095048801688724209698078569671875376948073176680", nil},
+		{"log(2)", "0.693147180559945309417232121458176568075500134360255254120680", nil},
+		{"sin(1)", "0.841470984807896506652502321630298999622563060798371065672752", nil},
+		{"cbrt(2)", "1.259921049894873164767210607278228350570251464701507980081975", nil},
+		{"asin(x) * 6", "3.141592653589793238462643383279502884197169399375105820974945", nil},
+		{"10^30 + 1", "1000000000000000000000000000001.000000000000000000000000000000000000000000000000000000000000", nil},
+		{"0.1 + 0.2", "0.300000000000000000000000000000000000000000000000000000000000", nil},
+		{"1/0", "", calculator.EvalError{calculator.ErrDivisionByZero, 1, 2}},
+		{"(0-8)^0.5", "", calculator.EvalError{calculator.ErrNotANumber, 5, 6}},
+		{"2 * log(0)", "", calculator.EvalError{calculator.ErrOverflow, 4, 10}},
+		{"2^(2^40)", "", calculator.EvalError{calculator.ErrOverflow, 1, 2}},
+		{"2^1e20000", "", calculator.EvalError{calculator.ErrOverflow, 1, 2}},
+		{"2^1e100000", "", calculator.EvalError{calculator.ErrOverflow, 1, 2}},
+		{"0.5^1e20000", "0.000000000000000000000000000000000000000000000000000000000000", nil},
+		{"2^-1e20000", "0.000000000000000000000000000000000000000000000000000000000000", nil},
+		{"(0-1)^1e30", "1.000000000000000000000000000000000000000000000000000000000000", nil},
+		{"(0-1)^(2^70)", "1.000000000000000000000000000000000000000000000000000000000000", nil},
+		{"(0-1)^(2^70+1)", "-1.000000000000000000000000000000000000000000000000000000000000", nil},
+		{"(0-0.5)^1e30", "0.000000000000000000000000000000000000000000000000000000000000", nil},
+		{"(0-2)^1e30", "", calculator.EvalError{calculator.ErrOverflow, 5, 6}},
+		{"(0-2)^1.5e30", "", calculator.EvalError{calculator.ErrOverflow, 5, 6}},
+		{"cos(1e100000)", "", calculator.EvalError{calculator.ErrOverflow, 0, 13}},
+		{"tan(-1e100000)", "", calculator.EvalError{calculator.ErrOverflow, 0, 14}},
+		{"mod(1e600000000, 3)", "", calculator.EvalError{calculator.ErrOverflow, 0, 19}},
+		{"double(2)", "", calculator.EvalError{calculator.ErrNotExact, 0, 9}},
+		{"y", "", calculator.EvalError{calculator.ErrUnknownVariable, 0, 1}},
+		{"1 + none", "", calculator.EvalError{calculator.ErrUnknownVariable, 4, 8}},
+	}
+
+	for _, tt := range tests {
+		testName := fmt.Sprint("Calculating ", tt.input)
+		t.Run(testName, func(t *testing.T) {
+			actual, err := c.EvalBigFloatWith(tt.input, vars)
+			if err != tt.err {
+				t.Fatalf("\nexpected: %v\nactual  : %v", tt.err, err)
+			}
+			if err != nil {
+				return
+			}
+			if actual.Prec() != 400 {
+				t.Fatalf("\nexpected: %v\nactual  : %v", 400, actual.Prec())
+			}
+			if got := actual.Text('f', 60); got != tt.want {
+				t.Fatalf("\nexpected: %v\nactual  : %v", tt.want, got)
+			}
+		})
+	}
+
+	t.Run("overridden built-in function", func(t *testing.T) {
+		c := calculator.New(calculator.WithFunction("sqrt", calculator.Function{MinArgs: 1, MaxArgs: 1, Fn: func(args ...float64) float64 {
+			return 0
+		}}))
+		_, err := c.EvalBigFloat("sqrt(4)")
+		expected := calculator.EvalError{calculator.ErrNotExact, 0, 7}
+		if err != expected {
+			t.Fatalf("\nexpected: %v\nactual  : %v", expected, err)
+		}
+	})
+}
+
+func TestDecimalMode(t *testing.T) {
+	price, err := calculator.ParseDecimal("19.99")
+	if err != nil {
+		t.Fatal(err)
+	}
+	vars := map[string]calculator.Decimal{"price": price, "rate": calculator.NewDecimal(125, 3)}
+
+	tests := []struct {
+		input string
+		mode  calculator.DecimalMode
+		want  string
+		err   error
+	}{
+		{"19.99*3", calculator.DecimalMode{Scale: 2}, "59.97", nil},
+		{"price*3", calculator.DecimalMode{Scale: 2}, "59.97", nil},
+		{"0.1+0.2", calculator.DecimalMode{Scale: 2}, "0.30", nil},
+		{"1/8", calculator.DecimalMode{Scale: 2, Rounding: calculator.RoundHalfEven}, "0.12", nil},
+		{"3/8", calculator.DecimalMode{Scale: 2, Rounding: calculator.RoundHalfEven}, "0.38", nil},
+		{"1/8", calculator.DecimalMode{Scale: 2, Rounding: calculator.RoundHalfUp}, "0.13", nil},
+		{"-1/8", calculator.DecimalMode{Scale: 2, Rounding: calculator.RoundHalfUp}, "-0.13", nil},
+		{"2/3", calculator.DecimalMode{Scale: 2, Rounding: calculator.RoundDown}, "0.66", nil},
+		{"-2/3", calculator.DecimalMode{Scale: 2, Rounding: calculator.RoundDown}, "-0.66", nil},
+		{"100 * rate", calculator.DecimalMode{Scale: 2}, "12.00", nil},
+		{"rate", calculator.DecimalMode{Scale: 2}, "0.12", nil},
+		{"1.1^2", calculator.DecimalMode{Scale: 4}, "1.2100", nil},
+		{"2^-2", calculator.DecimalMode{Scale: 1}, "0.2", nil},
+		{"10/4", calculator.DecimalMode{Scale: 0}, "2", nil},
+		{"1/4 * 4", calculator.DecimalMode{Scale: 1}, "0.8", nil},
+		{"10/4", calculator.DecimalMode{Scale: 1, ReportInexact: true}, "2.5", nil},
+		{"1 + 10/3", calculator.DecimalMode{Scale: 2, ReportInexact: true}, "", calculator.EvalError{calculator.ErrInexact, 6, 7}},
+		{"0.125 + 1", calculator.DecimalMode{Scale: 2, ReportInexact: true}, "", calculator.EvalError{calculator.ErrInexact, 0, 5}},
+		{"1/(1-1)", calculator.DecimalMode{Scale: 2}, "", calculator.EvalError{calculator.ErrDivisionByZero, 1, 2}},
+		{"2^0.5", calculator.DecimalMode{Scale: 2}, "", calculator.EvalError{calculator.ErrNotExact, 1, 2}},
+		{"round(1.5)", calculator.DecimalMode{Scale: 2}, "", calculator.EvalError{calculator.ErrNotExact, 0, 10}},
+	}
+
+	for _, tt := range tests {
+		testName := fmt.Sprint("Calculating ", tt.input, " with scale ", tt.mode.Scale)
+		t.Run(testName, func(t *testing.T) {
+			c := calculator.New(calculator.WithDecimalMode(tt.mode))
+			actual, err := c.EvalDecimalWith(tt.input, vars)
+			if err != tt.err {
+				t.Fatalf("\nexpected: %v\nactual  : %v", tt.err, err)
+			}
+			if err != nil {
+				return
+			}
+			if actual.String() != tt.want {
+				t.Fatalf("\nexpected: %v\nactual  : %v", tt.want, actual)
+			}
+		})
+	}
+
+	t.Run("parses decimals exactly", func(t *testing.T) {
+		tests := []struct {
+			input string
+			want  string
+			scale int
+		}{
+			{"19.99", "19.99", 2},
+			{"-0.50", "-0.50", 2},
+			{"1.5e3", "1500", 0},
+			{"1.25e-1", "0.125", 3},
+			{"5E-2", "0.05", 2},
+		}
+		for _, tt := range tests {
+			d, err := calculator.ParseDecimal(tt.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if d.String() != tt.want || d.Scale() != tt.scale {
+				t.Fatalf("\nexpected: %v %v\nactual  : %v %v", tt.want, tt.scale, d, d.Scale())
+			}
+		}
+		if _, err := calculator.ParseDecimal("1/3"); err == nil {
+			t.Fatal("expected an error for 1/3")
+		}
+	})
+}
+
+func TestTypedValues(t *testing.T) {
+	boolOf := func(b bool) float64 {
+		if b {
+			return 1
+		}
+		return 0
+	}
+	c := calculator.New(calculator.WithOperators(
+		calculator.OperatorSpec{Symbol: "==", Precedence: 0, Associativity: calculator.LeftAssociative, Arity: 2,
+			Fn: func(args ...float64) float64 { return boolOf(args[0] == args[1]) },
+			Overloads: []calculator.Overload{
+				{Operands: []calculator.Type{calculator.IntType, calculator.IntType}, Result: calculator.BoolType, Fn: func(args ...calculator.Value) (calculator.Value, error) {
+					return calculator.Bool(args[0] == args[1]), nil
+				}},
+				{Operands: []calculator.Type{calculator.FloatType, calculator.FloatType}, Result: calculator.BoolType, Fn: func(args ...calculator.Value) (calculator.Value, error) {
+					return calculator.Bool(args[0] == args[1]), nil
+				}},
+			}},
+		calculator.OperatorSpec{Symbol: "&&", Precedence: -1, Associativity: calculator.LeftAssociative, Arity: 2,
+			Fn: func(args ...float64) float64 { return boolOf(args[0] != 0 && args[1] != 0) },
+			Overloads: []calculator.Overload{
+				{Operands: []calculator.Type{calculator.BoolType, calculator.BoolType}, Result: calculator.BoolType, Fn: func(args ...calculator.Value) (calculator.Value, error) {
+					return args[0].(calculator.Bool) && args[1].(calculator.Bool), nil
+				}},
+			}},
+		calculator.OperatorSpec{Symbol: "!", Precedence: 3, Associativity: calculator.RightAssociative, Arity: 1,
+			Fn: func(args ...float64) float64 { return boolOf(args[0] == 0) },
+			Overloads: []calculator.Overload{
+				{Operands: []calculator.Type{calculator.BoolType}, Result: calculator.BoolType, Fn: func(args ...calculator.Value) (calculator.Value, error) {
+					return !args[0].(calculator.Bool), nil
+				}},
+			}},
+	))
+	vars := map[string]calculator.Value{"n": calculator.Int(math.MaxInt64), "ok": calculator.Bool(true), "x": calculator.Float(0.5), "none": nil}
+
+	tests := []struct {
+		input string
+		want  calculator.Value
+		err   error
+	}{
+		{"1 + 2", calculator.Int(3), nil},
+		{"1 + 2.5", calculator.Float(3.5), nil},
+		{"2 * 0x10 - -1", calculator.Int(33), nil},
+		{"+3", calculator.Int(3), nil},
+		{"-9223372036854775808", calculator.Int(math.MinInt64), nil},
+		{"7 / 2", calculator.Float(3.5), nil},
+		{"2 ^ 10", calculator.Float(1024), nil},
+		{"sqrt(16) + 1", calculator.Float(5), nil},
+		{"n - 1", calculator.Int(math.MaxInt64 - 1), nil},
+		{"x * 4", calculator.Float(2), nil},
+		{"1 + 1 == 2", calculator.Bool(true), nil},
+		{"1 == 1.5", calculator.Bool(false), nil},
+		{"2 * x == 1 && !!ok", calculator.Bool(true), nil},
+		{"n * 2", nil, calculator.EvalError{calculator.ErrOverflow, 2, 3}},
+		{"-n - 2", nil, calculator.EvalError{calculator.ErrOverflow, 3, 4}},
+		{"ok && 1", nil, calculator.EvalError{calculator.ErrTypeMismatch, 3, 5}},
+		{"-ok", nil, calculator.EvalError{calculator.ErrTypeMismatch, 0, 1}},
+		// "!1" is calculated by Fn as a Float, so "&&" has no Overload for it
+		{"!1 && ok", nil, calculator.EvalError{calculator.ErrTypeMismatch, 3, 5}},
+		{"(1 == 1) + 1", nil, calculator.EvalError{calculator.ErrTypeMismatch, 9, 10}},
+		{"sqrt(ok)", nil, calculator.EvalError{calculator.ErrTypeMismatch, 0, 8}},
+		{"y + 1", nil, calculator.EvalError{calculator.ErrUnknownVariable, 0, 1}},
+		{"1 + none", nil, calculator.EvalError{calculator.ErrUnknownVariable, 4, 8}},
+	}
+
+	for _, tt := range tests {
+		testName := fmt.Sprint("Calculating ", tt.input)
+		t.Run(testName, func(t *testing.T) {
+			actual, err := c.EvalValueWith(tt.input, vars)
+			if err != tt.err {
+				t.Fatalf("\nexpected: %v\nactual  : %v", tt.err, err)
+			}
+			if actual != tt.want {
+				t.Fatalf("\nexpected: %v (%v)\nactual  : %v (%v)", tt.want, tt.want.Type(), actual, actual.Type())
+			}
+		})
+	}
+
+	t.Run("types are checked before evaluation", func(t *testing.T) {
+		expr, err := c.CompileTyped("qty * 2 == total", map[string]calculator.Type{"qty": calculator.IntType, "total": calculator.IntType})
+		if err != nil {
+			t.Fatalf("\nexpected: nil\nactual  : %v", err)
+		}
+		if expr.Type() != calculator.BoolType {
+			t.Fatalf("\nexpected: %v\nactual  : %v", calculator.BoolType, expr.Type())
+		}
+		actual, err := expr.Eval(map[string]calculator.Value{"qty": calculator.Int(3), "total": calculator.Int(6)})
+		if err != nil || actual != calculator.Bool(true) {
+			t.Fatalf("\nexpected: true\nactual  : %v, %v", actual, err)
+		}
+		_, err = expr.Eval(map[string]calculator.Value{"qty": calculator.Float(3), "total": calculator.Int(6)})
+		expected := calculator.EvalError{calculator.ErrTypeMismatch, -1, -1}
+		if err != expected {
+			t.Fatalf("\nexpected: %v\nactual  : %v", expected, err)
+		}
+		_, err = expr.Eval(map[string]calculator.Value{"qty": calculator.Int(3), "total": nil})
+		expected = calculator.EvalError{calculator.ErrUnknownVariable, 11, 16}
+		if err != expected {
+			t.Fatalf("\nexpected: %v\nactual  : %v", expected, err)
+		}
+	})
+
+	t.Run("overloads must return their Result type", func(t *testing.T) {
+		c := calculator.New(calculator.WithOperators(calculator.OperatorSpec{Symbol: "?", Precedence: 1, Associativity: calculator.LeftAssociative, Arity: 2,
+			Fn: pow,
+			Overloads: []calculator.Overload{
+				{Operands: []calculator.Type{calculator.IntType, calculator.IntType}, Result: calculator.IntType, Fn: func(args ...calculator.Value) (calculator.Value, error) {
+					return calculator.Bool(true), nil
+				}},
+			}}))
+		_, err := c.EvalValue("1 ? 2 + 3")
+		expected := calculator.EvalError{calculator.ErrTypeMismatch, 2, 3}
+		if err != expected {
+			t.Fatalf("\nexpected: %v\nactual  : %v", expected, err)
+		}
+	})
+
+	t.Run("strict mode", func(t *testing.T) {
+		c := calculator.New(calculator.WithStrictMode())
+		tests := []struct {
+			input string
+			want  calculator.Value
+			err   error
+		}{
+			{"1.0/0", nil, calculator.EvalError{calculator.ErrDivisionByZero, 3, 4}},
+			{"1/0", nil, calculator.EvalError{calculator.ErrDivisionByZero, 1, 2}},
+			{"0^-1", nil, calculator.EvalError{calculator.ErrDivisionByZero, 1, 2}},
+			{"10^400", nil, calculator.EvalError{calculator.ErrOverflow, 2, 3}},
+			{"1e400 + 1", nil, calculator.EvalError{calculator.ErrOverflow, 0, 5}},
+			{"sqrt(0-1)", nil, calculator.EvalError{calculator.ErrNotANumber, 0, 9}},
+			{"7 / 2", calculator.Float(3.5), nil},
+			{"2 * 3", calculator.Int(6), nil},
+		}
+		for _, tt := range tests {
+			actual, err := c.EvalValue(tt.input)
+			if err != tt.err || actual != tt.want {
+				t.Fatalf("%s\nexpected: %v, %v\nactual  : %v, %v", tt.input, tt.want, tt.err, actual, err)
+			}
+		}
+	})
+
+	t.Run("Eval keeps calculating with float64", func(t *testing.T) {
+		actual, err := c.Eval("1 + 1 == 2 && !0")
+		if err != nil || actual != 1 {
+			t.Fatalf("\nexpected: 1\nactual  : %v, %v", actual, err)
+		}
+	})
+}
+
+func TestIntegerMode(t *testing.T) {
+	c := calculator.New(calculator.WithOperators(calculator.RemainderOperator()))
+	vars := map[string]int64{"size": 3, "max": math.MaxInt64}
+
+	tests := []struct {
+		input string
+		want  int64
+		err   error
+	}{
+		{"7/2", 3, nil},
+		{"-7/2", -3, nil},
+		{"7 % 4", 3, nil},
+		{"-7 % 4", -3, nil},
+		{"1 + 7 % 4 * 2", 7, nil},
+		{"size * 1024 ^ 2", 3 << 20, nil},
+		{"0xFF + 1", 256, nil},
+		{"(-1)^-3", -1, nil},
+		{"2^62 - 1 + 2^62", math.MaxInt64, nil},
+		{"-9223372036854775807 - 1", math.MinInt64, nil},
+		{"-9223372036854775808", math.MinInt64, nil},
+		{"-9_223_372_036_854_775_808 + 1", math.MinInt64 + 1, nil},
+		{"--9223372036854775808", 0, calculator.EvalError{calculator.ErrOverflow, 0, 1}},
+		{"-0x8000000000000000", math.MinInt64, nil},
+		{"- 9223372036854775808", math.MinInt64, nil},
+		{"2 * -9223372036854775808 / 4", 0, calculator.EvalError{calculator.ErrOverflow, 2, 3}},
+		{"-(9223372036854775808)", 0, calculator.EvalError{calculator.ErrOverflow, 2, 21}},
+		{"-9223372036854775808^1", 0, calculator.EvalError{calculator.ErrOverflow, 1, 20}},
+		{"-9223372036854775808(1)", 0, calculator.EvalError{calculator.ErrOverflow, 1, 20}},
+		{"max + 1", 0, calculator.EvalError{calculator.ErrOverflow, 4, 5}},
+		{"-max - 2", 0, calculator.EvalError{calculator.ErrOverflow, 5, 6}},
+		{"3037000500*3037000500", 0, calculator.EvalError{calculator.ErrOverflow, 10, 11}},
+		{"2^63", 0, calculator.EvalError{calculator.ErrOverflow, 1, 2}},
+		{"-(-max - 1)", 0, calculator.EvalError{calculator.ErrOverflow, 0, 1}},
+		{"(-max - 1) / -1", 0, calculator.EvalError{calculator.ErrOverflow, 11, 12}},
+		{"9223372036854775808", 0, calculator.EvalError{calculator.ErrOverflow, 0, 19}},
+		{"1 + 1.5", 0, calculator.EvalError{calculator.ErrNotInteger, 4, 7}},
+		{"1.0 * 2", 0, calculator.EvalError{calculator.ErrNotInteger, 0, 3}},
+		{"2e0", 0, calculator.EvalError{calculator.ErrNotInteger, 0, 3}},
+		{"1e3 / 7", 0, calculator.EvalError{calculator.ErrNotInteger, 0, 3}},
+		{"0xE + 1", 15, nil},
+		{"2^-1", 0, calculator.EvalError{calculator.ErrNotInteger, 1, 2}},
+		{"5/(2-2)", 0, calculator.EvalError{calculator.ErrDivisionByZero, 1, 2}},
+		{"5 % 0", 0, calculator.EvalError{calculator.ErrDivisionByZero, 2, 3}},
+		{"abs(1)", 0, calculator.EvalError{calculator.ErrNotExact, 0, 6}},
+	}
+
+	for _, tt := range tests {
+		testName := fmt.Sprint("Calculating ", tt.input)
+		t.Run(testName, func(t *testing.T) {
+			actual, err := c.EvalIntWith(tt.input, vars)
+			if err != tt.err {
+				t.Fatalf("\nexpected: %v\nactual  : %v", tt.err, err)
+			}
+			if actual != tt.want {
+				t.Fatalf("\nexpected: %v\nactual  : %v", tt.want, actual)
+			}
+		})
+	}
+
+	t.Run("custom prefix minus", func(t *testing.T) {
+		c := calculator.New(calculator.WithOperators(calculator.OperatorSpec{Symbol: "-", Precedence: 3, Associativity: calculator.RightAssociative, Arity: 1,
+			Fn: func(args ...float64) float64 { return -args[0] },
+		}))
+		for _, input := range []string{"-5", "-9223372036854775808"} {
+			_, err := c.EvalInt(input)
+			expected := calculator.EvalError{calculator.ErrNotExact, 0, 1}
+			if err != expected {
+				t.Fatalf("%s\nexpected: %v\nactual  : %v", input, expected, err)
+			}
+		}
+	})
+
+	t.Run("remainder in the other modes", func(t *testing.T) {
+		actual, err := c.Eval("-7.5 % 2")
+		if err != nil || actual != -1.5 {
+			t.Fatalf("\nexpected: -1.5\nactual  : %v, %v", actual, err)
+		}
+		exact, err := c.EvalRat("-7.5 % 2")
+		if err != nil || exact.Fraction() != "-3/2" {
+			t.Fatalf("\nexpected: -3/2\nactual  : %v, %v", exact, err)
+		}
+		typed, err := c.EvalValue("-7 % 4")
+		if err != nil || typed != calculator.Int(-3) {
+			t.Fatalf("\nexpected: -3\nactual  : %v, %v", typed, err)
+		}
+		_, err = c.EvalBigFloat("1e600000000 % 3")
+		expected := calculator.EvalError{calculator.ErrOverflow, 12, 13}
+		if err != expected {
+			t.Fatalf("\nexpected: %v\nactual  : %v", expected, err)
+		}
+	})
+}
+
+func BenchmarkEval(b *testing.B) {
+	c := calculator.New()
+	vars := map[string]float64{"price": 10, "qty": 3, "tax": 0.2, "discount": 5}
+	for i := 0; i < b.N; i++ {
+		vars["qty"] = float64(i)
+		if _, err := c.EvalWith(benchmarkInput, vars); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkCompiledEval(b *testing.B) {
+	c := calculator.New()
+	vars := map[string]float64{"price": 10, "qty": 3, "tax": 0.2, "discount": 5}
+	expr, err := c.Compile(benchmarkInput)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		vars["qty"] = float64(i)
+		if _, err := expr.Eval(vars); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func pow(args ...float64) float64 {
+	return math.Pow(args[0], args[1])
+}
@@ -0,0 +1,291 @@
+package calculator
+
+import (
+	"errors"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// ErrInexact is returned by EvalDecimal when the result had to be rounded to the scale, and DecimalMode.ReportInexact is set
+var ErrInexact = errors.New("result cannot be represented exactly with the decimal scale")
+
+// RoundingMode defines how the decimal results are rounded to the scale
+type RoundingMode int
+
+const (
+	// RoundHalfEven rounds to the nearest, and ties to the even digit, e.g. 0.125 is 0.12 and 0.135 is 0.14 with scale 2
+	RoundHalfEven RoundingMode = iota
+	// RoundHalfUp rounds to the nearest, and ties away from zero, e.g. 0.125 is 0.13 and -0.125 is -0.13 with scale 2
+	RoundHalfUp
+	// RoundDown rounds towards zero, e.g. 0.129 is 0.12 and -0.129 is -0.12 with scale 2
+	RoundDown
+)
+
+// DecimalMode configures EvalDecimal.
+//
+// Scale is the number of digits after the decimal point, which every intermediate result is rounded to with Rounding.
+//
+// ReportInexact makes EvalDecimal return ErrInexact, with the position of the operator or the number,
+// instead of rounding the result silently
+type DecimalMode struct {
+	Scale         int
+	Rounding      RoundingMode
+	ReportInexact bool
+}
+
+// DefaultDecimalMode is used by EvalDecimal when the Calculator is not created WithDecimalMode
+var DefaultDecimalMode = DecimalMode{Scale: 2, Rounding: RoundHalfEven}
+
+// WithDecimalMode sets the scale and the rounding of EvalDecimal.
+// Panics if the scale is negative
+func WithDecimalMode(mode DecimalMode) Option {
+	if mode.Scale < 0 {
+		panic("calculator: decimal scale cannot be negative")
+	}
+	return func(c *Calculator) {
+		c.decimalMode = mode
+	}
+}
+
+// Decimal is a base-10 fixed-point number, which is unscaled·10^-scale.
+// The zero value is 0
+type Decimal struct {
+	unscaled *big.Int
+	scale    int
+}
+
+// NewDecimal creates the Decimal unscaled·10^-scale, e.g. NewDecimal(1999, 2) is 19.99
+func NewDecimal(unscaled int64, scale int) Decimal {
+	return Decimal{big.NewInt(unscaled), scale}
+}
+
+// ParseDecimal parses the decimal number like "19.99", "-0.50" or "1.5e3" exactly.
+// The scale of the Decimal is the number of digits after the decimal point, so "-0.50" keeps its trailing zero
+func ParseDecimal(s string) (Decimal, error) {
+	r, ok := new(big.Rat).SetString(s)
+	if !ok || strings.ContainsAny(s, "/xXoObBpP") {
+		return Decimal{}, errors.New("calculator: invalid decimal " + s)
+	}
+	mantissa, exp := s, 0
+	if i := strings.IndexAny(s, "eE"); i >= 0 {
+		mantissa = s[:i]
+		exp, _ = strconv.Atoi(s[i+1:])
+	}
+	scale := 0
+	if i := strings.IndexByte(mantissa, '.'); i >= 0 {
+		scale = len(mantissa) - i - 1
+	}
+	if scale -= exp; scale < 0 {
+		scale = 0
+	}
+	d, _ := roundDecimal(r, scale, RoundDown)
+	return d, nil
+}
+
+// Scale returns the number of digits after the decimal point
+func (d Decimal) Scale() int {
+	return d.scale
+}
+
+// Unscaled returns the Decimal without the decimal point, e.g. 1999 for 19.99
+func (d Decimal) Unscaled() *big.Int {
+	if d.unscaled == nil {
+		return new(big.Int)
+	}
+	return new(big.Int).Set(d.unscaled)
+}
+
+// Rat returns the exact value of the Decimal
+func (d Decimal) Rat() *big.Rat {
+	denom := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(d.scale)), nil)
+	return new(big.Rat).SetFrac(d.Unscaled(), denom)
+}
+
+// Float64 returns the nearest float64 value of the Decimal
+func (d Decimal) Float64() float64 {
+	f, _ := d.Rat().Float64()
+	return f
+}
+
+// String renders the Decimal with all the digits of its scale, e.g. "59.97" or "-0.50"
+func (d Decimal) String() string {
+	return d.Rat().FloatString(d.scale)
+}
+
+// roundDecimal rounds r to the scale. exact is false if r had more digits than the scale
+func roundDecimal(r *big.Rat, scale int, mode RoundingMode) (d Decimal, exact bool) {
+	num := new(big.Int).Mul(r.Num(), new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(scale)), nil))
+	q, rem := new(big.Int).QuoRem(num, r.Denom(), new(big.Int))
+	if rem.Sign() == 0 {
+		return Decimal{q, scale}, true
+	}
+	// compare the remainder with the half of the denominator
+	half := new(big.Int).Abs(rem)
+	half.Lsh(half, 1)
+	cmp := half.Cmp(r.Denom())
+	awayFromZero := false
+	switch mode {
+	case RoundHalfEven:
+		awayFromZero = cmp > 0 || cmp == 0 && q.Bit(0) == 1
+	case RoundHalfUp:
+		awayFromZero = cmp >= 0
+	}
+	if awayFromZero {
+		q.Add(q, big.NewInt(int64(num.Sign())))
+	}
+	return Decimal{q, scale}, false
+}
+
+// DecimalEnv holds the values of the variables that are used in the expression, see Calculator.EvalDecimal
+type DecimalEnv map[string]Decimal
+
+// DecimalCalculatable is a node of the expression tree that can be calculated with Decimal
+type DecimalCalculatable interface {
+	CalculateDecimal(env DecimalEnv, mode DecimalMode) (Decimal, error)
+}
+
+// EvalDecimal calculates given mathematical expression with base-10 fixed-point numbers,
+// with the scale and the rounding set by WithDecimalMode, so that "19.99*3" is exactly "59.97".
+// "^" is calculated only with integer exponents.
+// Non-integer exponents, function calls and custom operators are reported as ErrNotExact
+func (c Calculator) EvalDecimal(input string) (Decimal, error) {
+	return c.EvalDecimalWith(input, nil)
+}
+
+// EvalDecimalWith calculates given mathematical expression with Decimal using the values of the variables in `vars`.
+// See #EvalDecimal
+func (c Calculator) EvalDecimalWith(input string, vars map[string]Decimal) (Decimal, error) {
+	// decimal results are always finite, so they are not wrapped in StrictNode
+	c.strict = false
+	headNode, err := c.compile(input)
+	if err != nil {
+		return Decimal{}, err
+	}
+	return calculateDecimal(headNode, vars, c.decimalMode)
+}
+
+// calculateDecimal calculates the node with Decimal. Nodes that don't support it are reported as ErrNotExact
+func calculateDecimal(node Calculatable, env DecimalEnv, mode DecimalMode) (Decimal, error) {
+	decimalNode, ok := node.(DecimalCalculatable)
+	if !ok {
+		return Decimal{}, EvalError{ErrNotExact, -1, -1}
+	}
+	return decimalNode.CalculateDecimal(env, mode)
+}
+
+// calculateDecimalOperands calculates the left and the right operands of a binary node with Decimal
+func calculateDecimalOperands(left, right Calculatable, env DecimalEnv, mode DecimalMode) (*big.Rat, *big.Rat, error) {
+	a, err := calculateDecimal(left, env, mode)
+	if err != nil {
+		return nil, nil, err
+	}
+	b, err := calculateDecimal(right, env, mode)
+	if err != nil {
+		return nil, nil, err
+	}
+	return a.Rat(), b.Rat(), nil
+}
+
+// round rounds the exact result of the operator to the scale of the mode, and reports it if it is not exact
+func (mode DecimalMode) round(r *big.Rat, startPos, endPos int) (Decimal, error) {
+	d, exact := roundDecimal(r, mode.Scale, mode.Rounding)
+	if !exact && mode.ReportInexact {
+		return Decimal{}, EvalError{ErrInexact, startPos, endPos}
+	}
+	return d, nil
+}
+
+func (n NumNode) CalculateDecimal(env DecimalEnv, mode DecimalMode) (Decimal, error) {
+	r, ok := parseRat(n.Literal)
+	if !ok {
+		return Decimal{}, EvalError{ErrOverflow, n.StartPos, n.EndPos}
+	}
+	return mode.round(r, n.StartPos, n.EndPos)
+}
+
+func (n VarNode) CalculateDecimal(env DecimalEnv, mode DecimalMode) (Decimal, error) {
+	val, ok := env[n.Name]
+	if !ok {
+		return Decimal{}, EvalError{ErrUnknownVariable, n.StartPos, n.EndPos}
+	}
+	return mode.round(val.Rat(), n.StartPos, n.EndPos)
+}
+
+func (n AddNode) CalculateDecimal(env DecimalEnv, mode DecimalMode) (Decimal, error) {
+	a, b, err := calculateDecimalOperands(n.Left, n.Right, env, mode)
+	if err != nil {
+		return Decimal{}, err
+	}
+	return mode.round(a.Add(a, b), n.StartPos, n.EndPos)
+}
+
+func (n SubNode) CalculateDecimal(env DecimalEnv, mode DecimalMode) (Decimal, error) {
+	a, b, err := calculateDecimalOperands(n.Left, n.Right, env, mode)
+	if err != nil {
+		return Decimal{}, err
+	}
+	return mode.round(a.Sub(a, b), n.StartPos, n.EndPos)
+}
+
+func (n NegNode) CalculateDecimal(env DecimalEnv, mode DecimalMode) (Decimal, error) {
+	a, err := calculateDecimal(n.Value, env, mode)
+	if err != nil {
+		return Decimal{}, err
+	}
+	unscaled := a.Unscaled()
+	return Decimal{unscaled.Neg(unscaled), a.scale}, nil
+}
+
+func (n MulNode) CalculateDecimal(env DecimalEnv, mode DecimalMode) (Decimal, error) {
+	a, b, err := calculateDecimalOperands(n.Left, n.Right, env, mode)
+	if err != nil {
+		return Decimal{}, err
+	}
+	return mode.round(a.Mul(a, b), n.StartPos, n.EndPos)
+}
+
+func (n DivNode) CalculateDecimal(env DecimalEnv, mode DecimalMode) (Decimal, error) {
+	a, b, err := calculateDecimalOperands(n.Left, n.Right, env, mode)
+	if err != nil {
+		return Decimal{}, err
+	}
+	if b.Sign() == 0 {
+		return Decimal{}, EvalError{ErrDivisionByZero, n.StartPos, n.EndPos}
+	}
+	return mode.round(a.Quo(a, b), n.StartPos, n.EndPos)
+}
+
+func (n RemNode) CalculateDecimal(env DecimalEnv, mode DecimalMode) (Decimal, error) {
+	a, b, err := calculateDecimalOperands(n.Left, n.Right, env, mode)
+	if err != nil {
+		return Decimal{}, err
+	}
+	if b.Sign() == 0 {
+		return Decimal{}, EvalError{ErrDivisionByZero, n.StartPos, n.EndPos}
+	}
+	return mode.round(remRat(a, b), n.StartPos, n.EndPos)
+}
+
+// CalculateDecimal raises to the power only if the exponent is an integer, and rounds the exact result once
+func (n PowNode) CalculateDecimal(env DecimalEnv, mode DecimalMode) (Decimal, error) {
+	a, b, err := calculateDecimalOperands(n.Left, n.Right, env, mode)
+	if err != nil {
+		return Decimal{}, err
+	}
+	res, err := powRat(a, b)
+	if err != nil {
+		return Decimal{}, EvalError{err, n.StartPos, n.EndPos}
+	}
+	return mode.round(res, n.StartPos, n.EndPos)
+}
+
+// CalculateDecimal reports ErrNotExact, because functions are calculated with float64
+func (n FuncNode) CalculateDecimal(env DecimalEnv, mode DecimalMode) (Decimal, error) {
+	return Decimal{}, EvalError{ErrNotExact, n.StartPos, n.EndPos}
+}
+
+// CalculateDecimal reports ErrNotExact, because custom operators are calculated with float64
+func (n OperatorNode) CalculateDecimal(env DecimalEnv, mode DecimalMode) (Decimal, error) {
+	return Decimal{}, EvalError{ErrNotExact, n.StartPos, n.EndPos}
+}
@@ -0,0 +1,50 @@
+package calculator
+
+// Expression is a compiled expression, created by Calculator.Compile.
+// It doesn't change after it is compiled, so it is safe to evaluate it from multiple goroutines
+// as long as the registered custom functions are safe for concurrent use
+type Expression struct {
+	input    string
+	headNode Calculatable
+}
+
+// Eval calculates the compiled expression using the values of the variables in `vars`
+func (e *Expression) Eval(vars map[string]float64) (float64, error) {
+	return e.headNode.Calculate(vars)
+}
+
+// String returns the original input of the expression
+func (e *Expression) String() string {
+	return e.input
+}
+
+// TypedExpression is a compiled and type checked expression, created by Calculator.CompileTyped.
+// Like Expression, it is safe to evaluate it from multiple goroutines
+type TypedExpression struct {
+	input    string
+	headNode Calculatable
+	types    TypeEnv
+	typ      Type
+}
+
+// Type returns the type of the result of the expression
+func (e *TypedExpression) Type() Type {
+	return e.typ
+}
+
+// Eval calculates the compiled expression using the values of the variables in `vars`.
+// Variables must have the types that the expression was compiled with, otherwise ErrTypeMismatch is returned.
+// Missing variables and variables with a nil Value are reported as ErrUnknownVariable
+func (e *TypedExpression) Eval(vars map[string]Value) (Value, error) {
+	for name, t := range e.types {
+		if val, ok := vars[name]; ok && val != nil && val.Type() != t {
+			return nil, EvalError{ErrTypeMismatch, -1, -1}
+		}
+	}
+	return calculateValue(e.headNode, vars)
+}
+
+// String returns the original input of the expression
+func (e *TypedExpression) String() string {
+	return e.input
+}
@@ -1,60 +1,259 @@
 package calculator
 
-import "math"
+import (
+	"math"
+	"math/big"
+)
+
+// Env holds the values of the variables that are used in the expression
+type Env map[string]float64
 
 type Calculatable interface {
-	Calculate() float64
+	Calculate(env Env) (float64, error)
+}
+
+// calculateOperands calculates the left and the right operands of a binary node
+func calculateOperands(left, right Calculatable, env Env) (float64, float64, error) {
+	a, err := left.Calculate(env)
+	if err != nil {
+		return 0, 0, err
+	}
+	b, err := right.Calculate(env)
+	if err != nil {
+		return 0, 0, err
+	}
+	return a, b, nil
 }
 
+// NumNode is a numeric literal. Literal is the text of the number in the user input,
+// which is parsed again by the exact evaluation modes, and StartPos and EndPos are its position
 type NumNode struct {
-	Value float64
+	Value    float64
+	Literal  string
+	StartPos int
+	EndPos   int
+}
+
+func (n NumNode) Calculate(env Env) (float64, error) {
+	return n.Value, nil
+}
+
+// VarNode looks up the value of the variable in the Env.
+// StartPos and EndPos are the position of the variable in the user input, used for reporting unknown variables
+type VarNode struct {
+	Name     string
+	StartPos int
+	EndPos   int
 }
 
-func (n NumNode) Calculate() float64 {
-	return n.Value
+func (n VarNode) Calculate(env Env) (float64, error) {
+	val, ok := env[n.Name]
+	if !ok {
+		return 0, EvalError{ErrUnknownVariable, n.StartPos, n.EndPos}
+	}
+	return val, nil
 }
 
+// AddNode adds Right to Left. StartPos and EndPos are the position of the operator
 type AddNode struct {
-	Left  Calculatable
-	Right Calculatable
+	Left     Calculatable
+	Right    Calculatable
+	StartPos int
+	EndPos   int
 }
 
-func (n AddNode) Calculate() float64 {
-	return n.Left.Calculate() + n.Right.Calculate()
+func (n AddNode) Calculate(env Env) (float64, error) {
+	a, b, err := calculateOperands(n.Left, n.Right, env)
+	if err != nil {
+		return 0, err
+	}
+	return a + b, nil
 }
 
+// SubNode subtracts Right from Left. StartPos and EndPos are the position of the operator
 type SubNode struct {
-	Left  Calculatable
-	Right Calculatable
+	Left     Calculatable
+	Right    Calculatable
+	StartPos int
+	EndPos   int
 }
 
-func (n SubNode) Calculate() float64 {
-	return n.Left.Calculate() - n.Right.Calculate()
+func (n SubNode) Calculate(env Env) (float64, error) {
+	a, b, err := calculateOperands(n.Left, n.Right, env)
+	if err != nil {
+		return 0, err
+	}
+	return a - b, nil
 }
 
+// NegNode negates the Value. StartPos and EndPos are the position of the operator,
+// used for reporting overflow in the integer evaluation mode
+type NegNode struct {
+	Value    Calculatable
+	StartPos int
+	EndPos   int
+}
+
+func (n NegNode) Calculate(env Env) (float64, error) {
+	a, err := n.Value.Calculate(env)
+	if err != nil {
+		return 0, err
+	}
+	return -a, nil
+}
+
+// MulNode multiplies Left by Right. StartPos and EndPos are the position of the operator
 type MulNode struct {
-	Left  Calculatable
-	Right Calculatable
+	Left     Calculatable
+	Right    Calculatable
+	StartPos int
+	EndPos   int
 }
 
-func (n MulNode) Calculate() float64 {
-	return n.Left.Calculate() * n.Right.Calculate()
+func (n MulNode) Calculate(env Env) (float64, error) {
+	a, b, err := calculateOperands(n.Left, n.Right, env)
+	if err != nil {
+		return 0, err
+	}
+	return a * b, nil
 }
 
+// DivNode divides Left by Right. StartPos and EndPos are the position of the operator,
+// used for reporting division by zero in the exact evaluation modes
 type DivNode struct {
-	Left  Calculatable
-	Right Calculatable
+	Left     Calculatable
+	Right    Calculatable
+	StartPos int
+	EndPos   int
+}
+
+func (n DivNode) Calculate(env Env) (float64, error) {
+	a, b, err := calculateOperands(n.Left, n.Right, env)
+	if err != nil {
+		return 0, err
+	}
+	return a / b, nil
+}
+
+// RemNode calculates the remainder of Left divided by Right, with the sign of Left like math.Mod.
+// StartPos and EndPos are the position of the operator. See RemainderOperator
+type RemNode struct {
+	Left     Calculatable
+	Right    Calculatable
+	StartPos int
+	EndPos   int
 }
 
-func (n DivNode) Calculate() float64 {
-	return n.Left.Calculate() / n.Right.Calculate()
+func (n RemNode) Calculate(env Env) (float64, error) {
+	a, b, err := calculateOperands(n.Left, n.Right, env)
+	if err != nil {
+		return 0, err
+	}
+	return math.Mod(a, b), nil
 }
 
+// PowNode raises Left to the power of Right. StartPos and EndPos are the position of the operator
 type PowNode struct {
-	Left  Calculatable
-	Right Calculatable
+	Left     Calculatable
+	Right    Calculatable
+	StartPos int
+	EndPos   int
+}
+
+func (n PowNode) Calculate(env Env) (float64, error) {
+	a, b, err := calculateOperands(n.Left, n.Right, env)
+	if err != nil {
+		return 0, err
+	}
+	return math.Pow(a, b), nil
+}
+
+// FuncNode calls the function with the calculated values of the arguments.
+// StartPos and EndPos are the position of the whole function call.
+// BigFn is the big.Float implementation of the built-in functions, nil for the custom functions
+type FuncNode struct {
+	Name     string
+	Fn       func(args ...float64) float64
+	Args     []Calculatable
+	StartPos int
+	EndPos   int
+	BigFn    func(prec uint, args ...*big.Float) (*big.Float, error)
+}
+
+func (n FuncNode) Calculate(env Env) (float64, error) {
+	args := make([]float64, len(n.Args))
+	for i, arg := range n.Args {
+		val, err := arg.Calculate(env)
+		if err != nil {
+			return 0, err
+		}
+		args[i] = val
+	}
+	return n.Fn(args...), nil
+}
+
+// OperatorNode calculates the operator defined by a custom OperatorSpec.
+// StartPos and EndPos are the position of the operator
+type OperatorNode struct {
+	Symbol   string
+	Fn       func(args ...float64) float64
+	Operands []Calculatable
+	StartPos int
+	EndPos   int
+}
+
+func (n OperatorNode) Calculate(env Env) (float64, error) {
+	args := make([]float64, len(n.Operands))
+	for i, operand := range n.Operands {
+		val, err := operand.Calculate(env)
+		if err != nil {
+			return 0, err
+		}
+		args[i] = val
+	}
+	return n.Fn(args...), nil
+}
+
+// StrictNode calculates the Node of a number, an operator or a function call in strict mode, see WithStrictMode.
+// It reports the non-finite results as EvalError, with StartPos and EndPos of the number, the operator or the function call
+type StrictNode struct {
+	Node     Calculatable
+	StartPos int
+	EndPos   int
+}
+
+func (n StrictNode) Calculate(env Env) (float64, error) {
+	res, err := n.Node.Calculate(env)
+	if err != nil {
+		return 0, err
+	}
+	if math.IsNaN(res) {
+		return 0, EvalError{ErrNotANumber, n.StartPos, n.EndPos}
+	}
+	if math.IsInf(res, 0) {
+		return 0, EvalError{n.infiniteError(env), n.StartPos, n.EndPos}
+	}
+	return res, nil
 }
 
-func (n PowNode) Calculate() float64 {
-	return math.Pow(n.Left.Calculate(), n.Right.Calculate())
+// infiniteError finds out why the result is infinite. Only "/" by zero and "^" of zero with a negative exponent
+// are division by zero, other infinite results like "10^400", "log(0)" or the number "1e400" are too large for float64.
+// Operands are calculated again, but only when the result is already known to be infinite
+func (n StrictNode) infiniteError(env Env) error {
+	switch node := n.Node.(type) {
+	case OverloadNode:
+		if node.floatNode != nil {
+			return StrictNode{node.floatNode, n.StartPos, n.EndPos}.infiniteError(env)
+		}
+	case DivNode:
+		if b, _ := node.Right.Calculate(env); b == 0 {
+			return ErrDivisionByZero
+		}
+	case PowNode:
+		a, b, _ := calculateOperands(node.Left, node.Right, env)
+		if a == 0 && b < 0 {
+			return ErrDivisionByZero
+		}
+	}
+	return ErrOverflow
 }
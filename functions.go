@@ -0,0 +1,105 @@
+package calculator
+
+import (
+	"math"
+	"strconv"
+	"unicode"
+)
+
+// Function is a function that can be called in the expression, e.g. "max(1, 2, 3)".
+// MinArgs and MaxArgs define how many arguments the function accepts. MaxArgs -1 means that function is variadic
+type Function struct {
+	MinArgs int
+	MaxArgs int
+	Fn      func(args ...float64) float64
+}
+
+// acceptsArgs checks if function can be called with `n` arguments
+func (f Function) acceptsArgs(n int) bool {
+	return n >= f.MinArgs && (f.MaxArgs == -1 || n <= f.MaxArgs)
+}
+
+// validate panics if the Function cannot be registered with the name, see WithFunction
+func (f Function) validate(name string) {
+	if !isIdentifier(name) {
+		panic("calculator: function name " + strconv.Quote(name) + " must be an identifier, like \"clamp\" or \"log_2\"")
+	}
+	if f.Fn == nil {
+		panic("calculator: function " + name + " must have Fn")
+	}
+	if f.MinArgs < 0 || f.MaxArgs < -1 {
+		panic("calculator: function " + name + " cannot have a negative number of arguments")
+	}
+	if f.MaxArgs != -1 && f.MinArgs > f.MaxArgs {
+		panic("calculator: function " + name + " cannot have more MinArgs than MaxArgs")
+	}
+}
+
+// isIdentifier checks if the name can be lexed as a single IDENT token
+func isIdentifier(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, ch := range name {
+		if ch != '_' && !unicode.IsLetter(ch) && (i == 0 || !unicode.IsDigit(ch)) {
+			return false
+		}
+	}
+	return true
+}
+
+// unaryFunction creates a 1 argument Function from the given math function
+func unaryFunction(fn func(float64) float64) Function {
+	return Function{1, 1, func(args ...float64) float64 {
+		return fn(args[0])
+	}}
+}
+
+// binaryFunction creates a 2 argument Function from the given math function
+func binaryFunction(fn func(float64, float64) float64) Function {
+	return Function{2, 2, func(args ...float64) float64 {
+		return fn(args[0], args[1])
+	}}
+}
+
+// builtinFunctions is the standard library of functions which are available in every expression
+var builtinFunctions = map[string]Function{
+	"abs":   unaryFunction(math.Abs),
+	"ceil":  unaryFunction(math.Ceil),
+	"floor": unaryFunction(math.Floor),
+	"round": unaryFunction(math.Round),
+	"trunc": unaryFunction(math.Trunc),
+	"sqrt":  unaryFunction(math.Sqrt),
+	"cbrt":  unaryFunction(math.Cbrt),
+	"exp":   unaryFunction(math.Exp),
+	"log":   unaryFunction(math.Log),
+	"log2":  unaryFunction(math.Log2),
+	"log10": unaryFunction(math.Log10),
+	"sin":   unaryFunction(math.Sin),
+	"cos":   unaryFunction(math.Cos),
+	"tan":   unaryFunction(math.Tan),
+	"asin":  unaryFunction(math.Asin),
+	"acos":  unaryFunction(math.Acos),
+	"atan":  unaryFunction(math.Atan),
+	"sinh":  unaryFunction(math.Sinh),
+	"cosh":  unaryFunction(math.Cosh),
+	"tanh":  unaryFunction(math.Tanh),
+	"atan2": binaryFunction(math.Atan2),
+	"pow":   binaryFunction(math.Pow),
+	"hypot": binaryFunction(math.Hypot),
+	"mod":   binaryFunction(math.Mod),
+	"min": {1, -1, func(args ...float64) float64 {
+		res := args[0]
+		for _, v := range args[1:] {
+			res = math.Min(res, v)
+		}
+		return res
+	}},
+	"max": {1, -1, func(args ...float64) float64 {
+		res := args[0]
+		for _, v := range args[1:] {
+			res = math.Max(res, v)
+		}
+		return res
+	}},
+}
@@ -0,0 +1,254 @@
+package calculator
+
+import (
+	"errors"
+	"math"
+	"math/big"
+	"strings"
+)
+
+// ErrNotInteger is returned by EvalInt for the numbers with a fraction or an exponent like "1.5" or "1e3",
+// and for the results that are not integers, like "2^-1"
+var ErrNotInteger = errors.New("result is not an integer")
+
+// minInt64Magnitude is -math.MinInt64, which doesn't fit in int64
+var minInt64Magnitude = new(big.Int).Neg(big.NewInt(math.MinInt64))
+
+// IntEnv holds the values of the variables that are used in the expression, see Calculator.EvalInt
+type IntEnv map[string]int64
+
+// IntCalculatable is a node of the expression tree that can be calculated with int64
+type IntCalculatable interface {
+	CalculateInt(env IntEnv) (int64, error)
+}
+
+// EvalInt calculates given mathematical expression with int64.
+// Numbers with a fraction or an exponent are reported as ErrNotInteger, "/" is the integer division truncated towards zero,
+// and "%" is the remainder, when the Calculator is created with the RemainderOperator.
+// "+", "-", "*" and "^" report int64 overflow as ErrOverflow instead of wrapping around.
+// Function calls and other custom operators are calculated with float64, so they are reported as ErrNotExact
+func (c Calculator) EvalInt(input string) (int64, error) {
+	return c.EvalIntWith(input, nil)
+}
+
+// EvalIntWith calculates given mathematical expression with int64 using the values of the variables in `vars`.
+// See #EvalInt
+func (c Calculator) EvalIntWith(input string, vars map[string]int64) (int64, error) {
+	// StrictNode doesn't know which operator it calculates, and overflow is always checked anyway
+	c.strict = false
+	headNode, err := c.compile(input)
+	if err != nil {
+		return 0, err
+	}
+	return calculateInt(headNode, vars)
+}
+
+// calculateInt calculates the node with int64. Nodes that don't support it are reported as ErrNotExact
+func calculateInt(node Calculatable, env IntEnv) (int64, error) {
+	intNode, ok := node.(IntCalculatable)
+	if !ok {
+		return 0, EvalError{ErrNotExact, -1, -1}
+	}
+	return intNode.CalculateInt(env)
+}
+
+// calculateIntOperands calculates the left and the right operands of a binary node with int64
+func calculateIntOperands(left, right Calculatable, env IntEnv) (int64, int64, error) {
+	a, err := calculateInt(left, env)
+	if err != nil {
+		return 0, 0, err
+	}
+	b, err := calculateInt(right, env)
+	if err != nil {
+		return 0, 0, err
+	}
+	return a, b, nil
+}
+
+// quoInt divides the integers, truncating towards zero. MinInt64/-1 doesn't fit in int64, so it is reported as ErrOverflow
+func quoInt(a, b int64) (int64, error) {
+	if b == 0 {
+		return 0, ErrDivisionByZero
+	}
+	if a == math.MinInt64 && b == -1 {
+		return 0, ErrOverflow
+	}
+	return a / b, nil
+}
+
+// remInt calculates the remainder of a/b, with the sign of `a`
+func remInt(a, b int64) (int64, error) {
+	if b == 0 {
+		return 0, ErrDivisionByZero
+	}
+	return a % b, nil
+}
+
+// powInt raises `a` to the power of `b` by squaring, and reports ErrOverflow instead of wrapping around.
+// Negative exponents are integers only for 1 and -1
+func powInt(a, b int64) (int64, error) {
+	if b < 0 {
+		switch a {
+		case 0:
+			return 0, ErrDivisionByZero
+		case 1:
+			return 1, nil
+		case -1:
+			if b%2 == 0 {
+				return 1, nil
+			}
+			return -1, nil
+		}
+		return 0, ErrNotInteger
+	}
+	res := int64(1)
+	for ; b > 0; b >>= 1 {
+		var err error
+		if b&1 == 1 {
+			if res, err = mulInt(res, a); err != nil {
+				return 0, err
+			}
+		}
+		// the last square is not used, and it can overflow even if the result doesn't
+		if b > 1 {
+			if a, err = mulInt(a, a); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return res, nil
+}
+
+// parseIntLiteral converts the numeric literal to an integer. Decimal literals with a fraction or an exponent,
+// like "1.0" or "2e3", are reported as ErrNotInteger, even if their value is an integer
+func parseIntLiteral(literal string) (*big.Int, error) {
+	literal = strings.ReplaceAll(literal, "_", "")
+	if n, ok := parseRadixLiteral(literal); ok {
+		return n, nil
+	}
+	if strings.ContainsAny(literal, ".eE") {
+		return nil, ErrNotInteger
+	}
+	n, _ := new(big.Int).SetString(literal, 10)
+	return n, nil
+}
+
+// isMinInt64Magnitude checks if the literal is 9223372036854775808, the magnitude of math.MinInt64
+func isMinInt64Magnitude(literal string) bool {
+	n, err := parseIntLiteral(literal)
+	return err == nil && n.Cmp(minInt64Magnitude) == 0
+}
+
+func (n NumNode) CalculateInt(env IntEnv) (int64, error) {
+	i, err := parseIntLiteral(n.Literal)
+	if err != nil {
+		return 0, EvalError{err, n.StartPos, n.EndPos}
+	}
+	if !i.IsInt64() {
+		return 0, EvalError{ErrOverflow, n.StartPos, n.EndPos}
+	}
+	return i.Int64(), nil
+}
+
+func (n VarNode) CalculateInt(env IntEnv) (int64, error) {
+	val, ok := env[n.Name]
+	if !ok {
+		return 0, EvalError{ErrUnknownVariable, n.StartPos, n.EndPos}
+	}
+	return val, nil
+}
+
+func (n AddNode) CalculateInt(env IntEnv) (int64, error) {
+	a, b, err := calculateIntOperands(n.Left, n.Right, env)
+	if err != nil {
+		return 0, err
+	}
+	res, err := addInt(a, b)
+	if err != nil {
+		return 0, EvalError{err, n.StartPos, n.EndPos}
+	}
+	return res, nil
+}
+
+func (n SubNode) CalculateInt(env IntEnv) (int64, error) {
+	a, b, err := calculateIntOperands(n.Left, n.Right, env)
+	if err != nil {
+		return 0, err
+	}
+	res, err := subInt(a, b)
+	if err != nil {
+		return 0, EvalError{err, n.StartPos, n.EndPos}
+	}
+	return res, nil
+}
+
+func (n NegNode) CalculateInt(env IntEnv) (int64, error) {
+	a, err := calculateInt(n.Value, env)
+	if err != nil {
+		return 0, err
+	}
+	res, err := negInt(a)
+	if err != nil {
+		return 0, EvalError{err, n.StartPos, n.EndPos}
+	}
+	return res, nil
+}
+
+func (n MulNode) CalculateInt(env IntEnv) (int64, error) {
+	a, b, err := calculateIntOperands(n.Left, n.Right, env)
+	if err != nil {
+		return 0, err
+	}
+	res, err := mulInt(a, b)
+	if err != nil {
+		return 0, EvalError{err, n.StartPos, n.EndPos}
+	}
+	return res, nil
+}
+
+// CalculateInt is the integer division, e.g. "7/2" is 3 and "-7/2" is -3
+func (n DivNode) CalculateInt(env IntEnv) (int64, error) {
+	a, b, err := calculateIntOperands(n.Left, n.Right, env)
+	if err != nil {
+		return 0, err
+	}
+	res, err := quoInt(a, b)
+	if err != nil {
+		return 0, EvalError{err, n.StartPos, n.EndPos}
+	}
+	return res, nil
+}
+
+func (n RemNode) CalculateInt(env IntEnv) (int64, error) {
+	a, b, err := calculateIntOperands(n.Left, n.Right, env)
+	if err != nil {
+		return 0, err
+	}
+	res, err := remInt(a, b)
+	if err != nil {
+		return 0, EvalError{err, n.StartPos, n.EndPos}
+	}
+	return res, nil
+}
+
+func (n PowNode) CalculateInt(env IntEnv) (int64, error) {
+	a, b, err := calculateIntOperands(n.Left, n.Right, env)
+	if err != nil {
+		return 0, err
+	}
+	res, err := powInt(a, b)
+	if err != nil {
+		return 0, EvalError{err, n.StartPos, n.EndPos}
+	}
+	return res, nil
+}
+
+// CalculateInt reports ErrNotExact, because functions are calculated with float64
+func (n FuncNode) CalculateInt(env IntEnv) (int64, error) {
+	return 0, EvalError{ErrNotExact, n.StartPos, n.EndPos}
+}
+
+// CalculateInt reports ErrNotExact, because custom operators are calculated with float64
+func (n OperatorNode) CalculateInt(env IntEnv) (int64, error) {
+	return 0, EvalError{ErrNotExact, n.StartPos, n.EndPos}
+}
@@ -1,9 +1,12 @@
 package lexer
 
 import (
+	"bufio"
 	"errors"
 	"fmt"
+	"io"
 	"strings"
+	"unicode"
 )
 
 // Token represents any meaningful data extracted during the process of Lexical analysis.
@@ -15,88 +18,458 @@ type MatcherFunc func(*Lexer) (Token Token, found bool)
 
 // Lexer takes the grammar rules and uses them to tokenize* the input
 type Lexer struct {
-	tokens   []int
-	matchers map[int]MatcherFunc
+	states       map[string]State
+	dispatch     map[string]dispatch
+	longestMatch bool
+	recover      bool
+	trivia       map[int]bool
+	// stack holds the names of the pushed states, the last one is the current state.
+	// It is never modified in place, so that it can be restored when the Matcher that changed it doesn't find a Token
+	stack []string
 
-	pos      int
-	input    []rune
-	inputLen int
-	done     bool
+	pos int
+	// input holds the runes starting from the offset `base`.
+	// When lexing a stream, runes are read from the `reader` on demand, and runes of the found Tokens are discarded
+	input   []rune
+	base    int
+	reader  *bufio.Reader
+	readErr error
+	// cursor is the last calculated Position, line and column of the next positions are counted from it
+	cursor Position
+	// trailing is the trivia after the last Token
+	trailing []Positioned
 }
 
-// ErrMatcherForgotToUnread is returned when matcher function forgets to Unread.
+// Position is a position in the input
+type Position struct {
+	// Offset is counted in runes
+	Offset int
+	// Line and Column are 1-based. Column is counted in runes
+	Line   int
+	Column int
+}
+
+// Positioned is a Token together with its start and end positions in the input.
+// Trivia holds the trivia Tokens that come right before the Token, see Options.Trivia
+type Positioned struct {
+	Token  Token
+	Start  Position
+	End    Position
+	Trivia []Positioned
+}
+
+// ErrMatcherForgotToUnread is returned when matcher function forgets to Unread or Reset,
+// and leaves the position moved after not finding the Token.
 // Look at the TestLexeIncorrectUsage testcase to see an example
 var ErrMatcherForgotToUnread = errors.New("one of the matcher functions didn't unread after failing to find what he wanted")
 
+// ErrMatcherFoundEmptyToken is returned when matcher function finds a Token without reading any rune,
+// which would make the Lexer find the same Token forever
+var ErrMatcherFoundEmptyToken = errors.New("one of the matcher functions found a token without reading anything")
+
 // Options represents the grammar rules.
 //
 // Tokens is a slice of unique TokenTypes to identify the Tokens.
 //
 // Matchers holds rules to find Tokens in the text. Keys of the map are the TokenTypes passed to `Tokens` options
+//
+// LongestMatch makes the Lexer try all the Matchers from the same position and take the Token that is the longest,
+// so that "**" is found instead of 2 "*" Tokens, no matter which one comes first in `Tokens`.
+// When multiple Tokens have the same length, the one that comes first in `Tokens` is taken.
+// By default, the first Matcher that finds a Token wins
+//
+// States holds the rules of the named states, that can be pushed by the Matchers with #PushState.
+// Tokens and Matchers are the rules of the DefaultState, which the Lexer starts in
+//
+// FirstRunes is an optional hint of the runes that a Token can start with. Keys of the map are the TokenTypes.
+// The Lexer calls only the Matchers that can start with the current rune, instead of all of them.
+// Tokens without FirstRunes, like identifiers that can start with any letter, are always tried
+//
+// Trivia is a slice of TokenTypes, like whitespace and comments, that don't appear in the main stream of Tokens.
+// They are attached to the Token that follows them, so that formatters can preserve them and parsers can ignore them.
+// Trivia at the end of the input can be found with #TrailingTrivia
+//
+// Recover makes the Lexer continue after an unknown symbol instead of stopping at it.
+// Every run of runes that no Matcher recognizes becomes an ErrorToken, see #LexPositioned and #Next
 type Options struct {
-	Tokens   []int
-	Matchers map[int]MatcherFunc
+	Tokens       []int
+	Matchers     map[int]MatcherFunc
+	FirstRunes   map[int]string
+	States       map[string]State
+	Trivia       []int
+	LongestMatch bool
+	Recover      bool
+}
+
+// State is a set of grammar rules that the Lexer uses while it is in that state,
+// so that the valid Tokens can change inside delimiters like quotes or brackets.
+//
+// Tokens, Matchers and FirstRunes work the same way as in Options
+type State struct {
+	Tokens     []int
+	Matchers   map[int]MatcherFunc
+	FirstRunes map[int]string
+}
+
+// dispatch is the index of the Tokens of a State by their FirstRunes
+type dispatch struct {
+	// byRune holds the Tokens that can start with the rune, in the order of `Tokens`
+	byRune map[rune][]int
+	// others holds the Tokens without FirstRunes, which are the only ones tried for the runes that are not in byRune
+	others []int
+}
+
+func newDispatch(state State) dispatch {
+	d := dispatch{byRune: map[rune][]int{}}
+	canStartWith := func(tokenType int, ch rune) bool {
+		firstRunes, ok := state.FirstRunes[tokenType]
+		return !ok || strings.ContainsRune(firstRunes, ch)
+	}
+	for _, tokenType := range state.Tokens {
+		if _, ok := state.FirstRunes[tokenType]; !ok {
+			d.others = append(d.others, tokenType)
+		}
+		for _, ch := range state.FirstRunes[tokenType] {
+			if _, ok := d.byRune[ch]; ok {
+				continue
+			}
+			for _, t := range state.Tokens {
+				if canStartWith(t, ch) {
+					d.byRune[ch] = append(d.byRune[ch], t)
+				}
+			}
+		}
+	}
+	return d
 }
 
+// candidates returns the Tokens whose Matchers can find a Token starting with the rune
+func (d dispatch) candidates(ch rune) []int {
+	if tokens, ok := d.byRune[ch]; ok {
+		return tokens
+	}
+	return d.others
+}
+
+// DefaultState is the name of the state which is made of Options.Tokens and Options.Matchers
+const DefaultState = ""
+
 // NewLexer creates a new Lexer with given options
 func NewLexer(ops Options) Lexer {
+	states := map[string]State{}
+	for name, state := range ops.States {
+		states[name] = state
+	}
+	states[DefaultState] = State{Tokens: ops.Tokens, Matchers: ops.Matchers, FirstRunes: ops.FirstRunes}
+	dispatches := make(map[string]dispatch, len(states))
+	for name, state := range states {
+		dispatches[name] = newDispatch(state)
+	}
+	trivia := map[int]bool{}
+	for _, tokenType := range ops.Trivia {
+		trivia[tokenType] = true
+	}
 	return Lexer{
-		tokens:   ops.Tokens,
-		matchers: ops.Matchers,
+		states:       states,
+		dispatch:     dispatches,
+		longestMatch: ops.LongestMatch,
+		recover:      ops.Recover,
+		trivia:       trivia,
 	}
 }
 
+// NewStreamLexer creates a new Lexer with given options, that reads the input from `r` rune by rune.
+// Tokens are returned one by one by #Next, so the whole input is never kept in memory
+func NewStreamLexer(r io.Reader, ops Options) Lexer {
+	l := NewLexer(ops)
+	l.reader = bufio.NewReader(r)
+	l.cursor = Position{0, 1, 1}
+	return l
+}
+
 // UnknownSymbolError is an error that Lexer returns when encountering a symbol that she can not recignize
 type UnknownSymbolError struct {
 	Symbol rune
+	Pos    Position
 }
 
 func (e UnknownSymbolError) Error() string {
-	return fmt.Sprintf("unknown character %q", e.Symbol)
+	return fmt.Sprintf("unknown character %q at %d:%d", e.Symbol, e.Pos.Line, e.Pos.Column)
+}
+
+// UnknownSymbolErrors is the list of all the unknown symbols that the Lexer found in Recover mode
+type UnknownSymbolErrors []UnknownSymbolError
+
+func (e UnknownSymbolErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "; ")
 }
 
-// Lex starts the lexical analysis and returns the slice of Tokens
+// ErrorToken is the Token that the Lexer emits in Recover mode for a run of runes that no Matcher recognizes
+type ErrorToken struct {
+	Text string
+}
+
+// Lex starts the lexical analysis and returns the slice of Tokens. See #LexPositioned for the Recover mode
 func (l *Lexer) Lex(input string) ([]Token, error) {
+	positioned, err := l.LexPositioned(input)
+	if positioned == nil {
+		return nil, err
+	}
+	tokens := make([]Token, len(positioned))
+	for i, p := range positioned {
+		tokens[i] = p.Token
+	}
+	return tokens, err
+}
+
+// LexPositioned starts the lexical analysis and returns the slice of Tokens with their positions in the input.
+//
+// In Recover mode, the unknown symbols don't stop the analysis. All the Tokens are returned together with ErrorTokens,
+// and the error is UnknownSymbolErrors that holds the position of every ErrorToken
+func (l *Lexer) LexPositioned(input string) ([]Positioned, error) {
 	l.input = []rune(input)
-	l.inputLen = len(l.input)
+	l.base = 0
+	l.reader = nil
+	l.readErr = nil
 	l.pos = 0
-	l.done = false
-	tokens := []Token{}
+	l.cursor = Position{0, 1, 1}
+	l.stack = nil
+	l.trailing = nil
+	tokens := []Positioned{}
+	var symbolErrs UnknownSymbolErrors
 
 	for {
-		if l.done {
+		token, found, err := l.next()
+		if symbolErr, ok := err.(UnknownSymbolError); ok && l.recover {
+			symbolErrs = append(symbolErrs, symbolErr)
+		} else if err != nil {
+			return nil, err
+		}
+		if !found {
 			break
 		}
-		var token Token
-		var found bool
-		for _, tokenType := range l.tokens {
-			matcherFn, ok := l.matchers[tokenType]
-			if !ok {
-				panic("no matcher exists for ..")
-			}
-			token, found = matcherFn(l)
-			if found {
-				tokens = append(tokens, token)
-			}
+		tokens = append(tokens, token)
+	}
+	if symbolErrs != nil {
+		return tokens, symbolErrs
+	}
+	return tokens, nil
+}
 
-			if l.done {
-				return tokens, nil
-			}
+// Next returns the next Token of the stream created by NewStreamLexer, together with its position.
+// Returns io.EOF at the end of the input, or the error that the reader returned.
+// The Token that reaches the position where the reader failed is not returned, because it may be incomplete.
+//
+// In Recover mode, an unknown symbol is returned as an ErrorToken together with its UnknownSymbolError,
+// and the next call continues after it
+func (l *Lexer) Next() (Positioned, error) {
+	l.discard()
+	token, found, err := l.next()
+	if err != nil {
+		return token, err
+	}
+	// the Token that ends where the reader failed may be cut off by the error, e.g. "12" of "123"
+	if l.readErr != nil && l.readErr != io.EOF && l.pos-l.base >= len(l.input) {
+		return Positioned{}, l.readErr
+	}
+	if !found {
+		return Positioned{}, io.EOF
+	}
+	return token, nil
+}
 
-			if found {
-				break
-			}
+// discard forgets the runes before the current position, which are not needed anymore when lexing a stream
+func (l *Lexer) discard() {
+	if l.reader == nil {
+		return
+	}
+	l.positionAt(l.pos)
+	n := copy(l.input, l.input[l.pos-l.base:])
+	l.input = l.input[:n]
+	l.base = l.pos
+}
+
+// fill makes sure that the rune in the current position is in the buffer,
+// by reading it from the stream if needed. returns false at the end of the input
+func (l *Lexer) fill() bool {
+	for l.pos-l.base >= len(l.input) {
+		if l.reader == nil || l.readErr != nil {
+			return false
 		}
+		r, _, err := l.reader.ReadRune()
+		if err != nil {
+			l.readErr = err
+			return false
+		}
+		l.input = append(l.input, r)
+	}
+	return true
+}
 
-		if !found {
-			ch, done := l.ReadNext()
-			if done {
-				return nil, ErrMatcherForgotToUnread
-			}
-			return nil, UnknownSymbolError{ch}
+// TrailingTrivia returns the trivia Tokens at the end of the input, which don't have a following Token to be attached to
+func (l *Lexer) TrailingTrivia() []Positioned {
+	return l.trailing
+}
+
+// next finds the next Token that is not trivia, and attaches the trivia before it. found is false at the end of the input.
+// In Recover mode, an unknown symbol is returned as a found ErrorToken together with its UnknownSymbolError
+func (l *Lexer) next() (token Positioned, found bool, err error) {
+	var trivia []Positioned
+	for {
+		token, found, isTrivia, err := l.nextToken()
+		if found && isTrivia {
+			trivia = append(trivia, token)
+			continue
+		}
+		if found {
+			token.Trivia = trivia
+		} else if err == nil {
+			l.trailing = trivia
 		}
+		return token, found, err
 	}
-	return tokens, nil
+}
+
+// nextToken finds the Token that starts at the current position. isTrivia is true if the Token is one of Options.Trivia
+func (l *Lexer) nextToken() (token Positioned, found, isTrivia bool, err error) {
+	if !l.fill() {
+		return Positioned{}, false, false, nil
+	}
+	start := l.positionAt(l.pos)
+	tokenType, t, found, err := l.match()
+	if err != nil {
+		return Positioned{}, false, false, err
+	}
+	if found {
+		return Positioned{Token: t, Start: start, End: l.positionAt(l.pos)}, true, l.trivia[tokenType], nil
+	}
+
+	ch, _ := l.ReadNext()
+	symbolErr := UnknownSymbolError{ch, start}
+	if !l.recover {
+		return Positioned{}, false, false, symbolErr
+	}
+	text, err := l.skipUnknown(ch)
+	if err != nil {
+		return Positioned{}, false, false, err
+	}
+	return Positioned{Token: ErrorToken{text}, Start: start, End: l.positionAt(l.pos)}, true, false, symbolErr
+}
+
+// match tries the Matchers of the current state that can start with the current rune,
+// and moves the position to the end of the found Token.
+// Only the state changes of the Matcher whose Token is taken are kept. The current rune must be already filled
+func (l *Lexer) match() (tokenType int, token Token, found bool, err error) {
+	mark := l.Mark()
+	stack := l.stack
+	state := l.states[l.State()]
+	candidates := l.dispatch[l.State()].candidates(l.input[l.pos-l.base])
+	// the longest Token found so far, where it ends, and the states after it. Used only with LongestMatch
+	var longest Token
+	var longestType int
+	longestEnd := mark
+	longestStack := stack
+	for _, tokenType := range candidates {
+		matcherFn, ok := state.Matchers[tokenType]
+		if !ok {
+			panic("no matcher exists for ..")
+		}
+		t, found := matcherFn(l)
+		if !found && l.pos != mark.pos {
+			return 0, nil, false, ErrMatcherForgotToUnread
+		}
+		if found && l.pos == mark.pos {
+			return 0, nil, false, ErrMatcherFoundEmptyToken
+		}
+		if found && !l.longestMatch {
+			return tokenType, t, true, nil
+		}
+		if found && l.pos > longestEnd.pos {
+			longest, longestType, longestEnd, longestStack = t, tokenType, l.Mark(), l.stack
+		}
+		l.Reset(mark)
+		l.stack = stack
+	}
+	if longestEnd != mark {
+		l.Reset(longestEnd)
+		l.stack = longestStack
+		return longestType, longest, true, nil
+	}
+	return 0, nil, false, nil
+}
+
+// skipUnknown reads the runes after the unknown `first` rune, until one of the Matchers finds a Token.
+// returns the whole run of unknown runes
+func (l *Lexer) skipUnknown(first rune) (string, error) {
+	sb := strings.Builder{}
+	sb.WriteRune(first)
+	stack := l.stack
+	for l.fill() {
+		mark := l.Mark()
+		_, _, found, err := l.match()
+		if err != nil {
+			return "", err
+		}
+		if found {
+			l.Reset(mark)
+			l.stack = stack
+			break
+		}
+		ch, _ := l.ReadNext()
+		sb.WriteRune(ch)
+	}
+	return sb.String(), nil
+}
+
+// positionAt calculates the Position of the given offset.
+// offset cannot be smaller than the offset of the last calculated Position
+func (l *Lexer) positionAt(offset int) Position {
+	for l.cursor.Offset < offset {
+		ch := l.input[l.cursor.Offset-l.base]
+		l.cursor.Offset++
+		l.cursor.Column++
+		if ch == '\n' {
+			l.cursor.Line++
+			l.cursor.Column = 1
+		}
+	}
+	return l.cursor
+}
+
+// State returns the name of the current state
+func (l *Lexer) State() string {
+	if len(l.stack) == 0 {
+		return DefaultState
+	}
+	return l.stack[len(l.stack)-1]
+}
+
+// PushState makes the Lexer use the rules of the given state, starting from the next Token.
+// It is meant to be called by a Matcher that finds an opening delimiter
+func (l *Lexer) PushState(name string) {
+	if _, ok := l.states[name]; !ok {
+		panic(fmt.Sprintf("no state exists with name %q", name))
+	}
+	// full slice expression makes append copy the stack instead of modifying it
+	l.stack = append(l.stack[:len(l.stack):len(l.stack)], name)
+}
+
+// PopState goes back to the state that was used before the last #PushState.
+// It is meant to be called by a Matcher that finds a closing delimiter
+func (l *Lexer) PopState() {
+	if len(l.stack) == 0 {
+		panic("no state to pop")
+	}
+	l.stack = l.stack[:len(l.stack)-1]
+}
+
+// Pos returns the position of the next rune that will be read, counted in runes
+func (l *Lexer) Pos() int {
+	return l.pos
 }
 
 // ReadNext returns the next rune of the input
@@ -104,45 +477,99 @@ func (l *Lexer) Lex(input string) ([]Token, error) {
 // After using ReadNext and not finding what you want,
 // user is responsible for calling #Unread to not let other matchers pass that character
 func (l *Lexer) ReadNext() (r rune, done bool) {
-	if l.pos >= l.inputLen {
-		l.done = true
+	if !l.fill() {
 		return r, true
 	}
-	r = l.input[l.pos]
+	r = l.input[l.pos-l.base]
 	l.pos++
 	return r, false
 }
 
-// Unread unreads the last rune. Cannot be called more than one
+// Unread unreads the last rune. Cannot be called more than one,
+// use #Mark and #Reset to go back more than 1 rune
 func (l *Lexer) Unread() {
-	l.done = false
 	l.pos--
 }
 
+// Mark is a checkpoint of the Lexer's position. See #Mark
+type Mark struct {
+	pos int
+}
+
+// Mark returns the checkpoint of the current position.
+// It is used with #Reset to go back to the checkpoint, after trying a multi-rune pattern and not finding it
+func (l *Lexer) Mark() Mark {
+	return Mark{l.pos}
+}
+
+// Reset goes back to the checkpoint, as if nothing was read after #Mark
+func (l *Lexer) Reset(m Mark) {
+	l.pos = m.pos
+}
+
 // ReadInt tries to read an integer (\d+) if. returns the number in string format if found
 func (l *Lexer) ReadInt() (string, bool) {
 	str, ok := l.ReadBetween('0', '9')
 	return str, ok
 }
 
-// ReadIntOrFloat tries to read an integer or float (\d+\.\d+). returns the number in string format if found
+// ReadIntOrFloat tries to read an integer or float (\d+(\.\d+)?). returns the number in string format if found.
+// In cases like "123.", only "123" is read
 func (l *Lexer) ReadIntOrFloat() (string, bool) {
 	decimal, ok := l.ReadBetween('0', '9')
 	if !ok {
 		return decimal, ok
 	}
+	beforeDot := l.Mark()
 	if !l.ReadChar('.') {
 		return decimal, ok
 	}
 	floating, ok := l.ReadBetween('0', '9')
-	// TODO: can't unread more than 1 times. One options is keep a customizable-sized Queue for read Runes.
-	// For now, treat cases like "123." as "123.0" and take them as valid floats
 	if !ok {
-		return decimal + ".", true
+		l.Reset(beforeDot)
+		return decimal, true
 	}
 	return decimal + "." + floating, ok
 }
 
+// ReadString tries to read the requested string. Nothing is read if the input doesn't continue with the string
+func (l *Lexer) ReadString(want string) bool {
+	start := l.Mark()
+	for _, ch := range want {
+		got, done := l.ReadNext()
+		if done || got != ch {
+			l.Reset(start)
+			return false
+		}
+	}
+	return true
+}
+
+// ReadIdentifier tries to read an identifier that starts with a letter or '_', followed by letters, digits or '_'.
+// returns the identifier if found
+func (l *Lexer) ReadIdentifier() (string, bool) {
+	ch, done := l.ReadNext()
+	if done {
+		return "", false
+	}
+	if !isIdentifierStart(ch) {
+		l.Unread()
+		return "", false
+	}
+
+	sb := strings.Builder{}
+	for isIdentifierStart(ch) || unicode.IsDigit(ch) {
+		sb.WriteRune(ch)
+		ch, done = l.ReadNext()
+		if done {
+			return sb.String(), true
+		}
+	}
+	// no error, no io.EOF
+	l.Unread()
+	return sb.String(), true
+}
+
 // ReadChar tries to read the requeted char.
 func (l *Lexer) ReadChar(want rune) bool {
 	ch, done := l.ReadNext()
@@ -214,6 +641,10 @@ func (l *Lexer) ReadUntil(runes []rune) (string, bool) {
 	return sb.String(), true
 }
 
+func isIdentifierStart(ch rune) bool {
+	return ch == '_' || unicode.IsLetter(ch)
+}
+
 func isBetween(ch, from, to rune) bool {
 	return ch >= from && ch <= to
 }
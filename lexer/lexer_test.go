@@ -1,9 +1,14 @@
 package lexer_test
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"reflect"
+	"runtime"
+	"strings"
 	"testing"
+	"testing/iotest"
 
 	"github.com/DavudSafarli/design-calculator-challenge/lexer"
 )
@@ -76,7 +81,7 @@ func TestLexer(t *testing.T) {
 			"7-7/7+7*14",
 			[]lexer.Token{Token{NUM, "7"}, Token{SUB, ""}, Token{NUM, "7"}, Token{DIV, ""}, Token{NUM, "7"}, Token{ADD, ""}, Token{NUM, "7"}, Token{MUL, ""}, Token{NUM, "14"}},
 		},
-		{"123.456+5.", []lexer.Token{Token{NUM, "123.456"}, Token{ADD, ""}, Token{NUM, "5."}}},
+		{"123.456+5", []lexer.Token{Token{NUM, "123.456"}, Token{ADD, ""}, Token{NUM, "5"}}},
 		{"2.25*4", []lexer.Token{Token{NUM, "2.25"}, Token{MUL, ""}, Token{NUM, "4"}}},
 		{
 			"(2+2.22)",
@@ -108,7 +113,7 @@ func TestLexeInvalidInput(t *testing.T) {
 	})
 
 	_, err := lex.Lex("+-")
-	expected := lexer.UnknownSymbolError{'-'}
+	expected := lexer.UnknownSymbolError{'-', lexer.Position{1, 1, 2}}
 
 	if err != expected {
 		t.Fatalf("\nexpected: %v\nactual  : %v", expected, err)
@@ -144,3 +149,973 @@ func TestLexeIncorrectUsage(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestReadIdentifier(t *testing.T) {
+	const IDENT = 0
+	lex := lexer.NewLexer(lexer.Options{
+		Tokens: []int{IDENT, ADD},
+		Matchers: map[int]lexer.MatcherFunc{
+			ADD: createOneCharMatcher('+', ADD),
+			IDENT: func(l *lexer.Lexer) (lexer.Token, bool) {
+				val, ok := l.ReadIdentifier()
+				if !ok {
+					return Token{}, false
+				}
+				return Token{IDENT, val}, true
+			},
+		},
+	})
+
+	got, err := lex.Lex("x+_tax1+çay")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []lexer.Token{Token{IDENT, "x"}, Token{ADD, ""}, Token{IDENT, "_tax1"}, Token{ADD, ""}, Token{IDENT, "çay"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Tokenize() = %v, want %v", got, want)
+	}
+
+	_, err = lex.Lex("1x")
+	expected := lexer.UnknownSymbolError{'1', lexer.Position{0, 1, 1}}
+	if err != expected {
+		t.Fatalf("\nexpected: %v\nactual  : %v", expected, err)
+	}
+}
+
+func TestReadString(t *testing.T) {
+	const POW2 = 10
+	lex := lexer.NewLexer(lexer.Options{
+		Tokens: []int{POW2, MUL},
+		Matchers: map[int]lexer.MatcherFunc{
+			MUL: createOneCharMatcher('*', MUL),
+			POW2: func(l *lexer.Lexer) (lexer.Token, bool) {
+				if !l.ReadString("**") {
+					return Token{}, false
+				}
+				return Token{POW2, "**"}, true
+			},
+		},
+	})
+
+	got, err := lex.Lex("***")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []lexer.Token{Token{POW2, "**"}, Token{MUL, ""}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Tokenize() = %v, want %v", got, want)
+	}
+}
+
+func TestMarkAndReset(t *testing.T) {
+	const ARROW = 10
+	lex := lexer.NewLexer(lexer.Options{
+		Tokens: []int{ARROW, SUB, NUM},
+		Matchers: map[int]lexer.MatcherFunc{
+			SUB: createOneCharMatcher('-', SUB),
+			NUM: func(l *lexer.Lexer) (lexer.Token, bool) {
+				val, ok := l.ReadIntOrFloat()
+				if !ok {
+					return Token{}, false
+				}
+				return Token{NUM, val}, true
+			},
+			// "--->" is an arrow, any other number of '-' is not
+			ARROW: func(l *lexer.Lexer) (lexer.Token, bool) {
+				mark := l.Mark()
+				for _, want := range "--->" {
+					if !l.ReadChar(want) {
+						l.Reset(mark)
+						return Token{}, false
+					}
+				}
+				return Token{ARROW, "--->"}, true
+			},
+		},
+	})
+
+	tests := []struct {
+		input string
+		want  []lexer.Token
+		err   error
+	}{
+		{"--->1", []lexer.Token{Token{ARROW, "--->"}, Token{NUM, "1"}}, nil},
+		{"--1", []lexer.Token{Token{SUB, ""}, Token{SUB, ""}, Token{NUM, "1"}}, nil},
+		{"---1", []lexer.Token{Token{SUB, ""}, Token{SUB, ""}, Token{SUB, ""}, Token{NUM, "1"}}, nil},
+		{"1.5--->", []lexer.Token{Token{NUM, "1.5"}, Token{ARROW, "--->"}}, nil},
+		{"1.-2", nil, lexer.UnknownSymbolError{'.', lexer.Position{1, 1, 2}}},
+		{"12.", nil, lexer.UnknownSymbolError{'.', lexer.Position{2, 1, 3}}},
+	}
+
+	for _, tt := range tests {
+		testName := fmt.Sprint("Tokenizing", tt.input)
+		t.Run(testName, func(t *testing.T) {
+			got, err := lex.Lex(tt.input)
+			if err != tt.err {
+				t.Fatalf("\nexpected: %v\nactual  : %v", tt.err, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Tokenize() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLexeMatcherForgotToReset(t *testing.T) {
+	lex := lexer.NewLexer(lexer.Options{
+		Tokens: []int{SUB, ADD},
+		Matchers: map[int]lexer.MatcherFunc{
+			// reads 2 runes, but forgets to Reset after not finding "--"
+			SUB: func(l *lexer.Lexer) (lexer.Token, bool) {
+				if l.ReadChar('-') && l.ReadChar('-') {
+					return Token{SUB, "--"}, true
+				}
+				return Token{}, false
+			},
+			ADD: createOneCharMatcher('+', ADD),
+		},
+	})
+
+	_, err := lex.Lex("--+-+")
+	expected := lexer.ErrMatcherForgotToUnread
+	if err != expected {
+		t.Fatalf("\nexpected: %v\nactual  : %v", expected, err)
+	}
+}
+
+func TestLexeMatcherFoundEmptyToken(t *testing.T) {
+	lex := lexer.NewLexer(lexer.Options{
+		Tokens: []int{ADD},
+		Matchers: map[int]lexer.MatcherFunc{
+			ADD: func(l *lexer.Lexer) (lexer.Token, bool) {
+				return Token{ADD, ""}, true
+			},
+		},
+	})
+
+	_, err := lex.Lex("+")
+	expected := lexer.ErrMatcherFoundEmptyToken
+	if err != expected {
+		t.Fatalf("\nexpected: %v\nactual  : %v", expected, err)
+	}
+}
+
+func TestLexPositioned(t *testing.T) {
+	lex := lexer.NewLexer(lexer.Options{
+		Tokens: []int{NUM, ADD, SPACE},
+		Matchers: map[int]lexer.MatcherFunc{
+			ADD: createOneCharMatcher('+', ADD),
+			NUM: func(l *lexer.Lexer) (token lexer.Token, found bool) {
+				val, ok := l.ReadIntOrFloat()
+				if !ok {
+					return Token{}, false
+				}
+				return Token{NUM, val}, true
+			},
+			SPACE: func(l *lexer.Lexer) (token lexer.Token, found bool) {
+				val, ok := l.ReadUntil([]rune{' ', '\t', '\n'})
+				if !ok {
+					return Token{}, false
+				}
+				return Token{SPACE, val}, true
+			},
+		},
+	})
+
+	got, err := lex.LexPositioned("12+\n 3.5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []lexer.Positioned{
+		{Token{NUM, "12"}, lexer.Position{0, 1, 1}, lexer.Position{2, 1, 3}, nil},
+		{Token{ADD, ""}, lexer.Position{2, 1, 3}, lexer.Position{3, 1, 4}, nil},
+		{Token{SPACE, "\n "}, lexer.Position{3, 1, 4}, lexer.Position{5, 2, 2}, nil},
+		{Token{NUM, "3.5"}, lexer.Position{5, 2, 2}, lexer.Position{8, 2, 5}, nil},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Tokenize() = %v, want %v", got, want)
+	}
+
+	_, err = lex.LexPositioned("1 +\n ç")
+	expected := lexer.UnknownSymbolError{'ç', lexer.Position{5, 2, 2}}
+	if err != expected {
+		t.Fatalf("\nexpected: %v\nactual  : %v", expected, err)
+	}
+}
+
+// repeatReader reads the text `count` times, without keeping the whole input in memory
+type repeatReader struct {
+	text  string
+	count int
+	off   int
+}
+
+func (r *repeatReader) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) && r.count > 0 {
+		copied := copy(p[n:], r.text[r.off:])
+		n += copied
+		r.off += copied
+		if r.off == len(r.text) {
+			r.off = 0
+			r.count--
+		}
+	}
+	if n == 0 {
+		return 0, io.EOF
+	}
+	return n, nil
+}
+
+func TestStreamLexer(t *testing.T) {
+	ops := lexer.Options{
+		Tokens: []int{NUM, ADD, SPACE},
+		Matchers: map[int]lexer.MatcherFunc{
+			ADD: createOneCharMatcher('+', ADD),
+			NUM: func(l *lexer.Lexer) (token lexer.Token, found bool) {
+				val, ok := l.ReadIntOrFloat()
+				if !ok {
+					return Token{}, false
+				}
+				return Token{NUM, val}, true
+			},
+			SPACE: func(l *lexer.Lexer) (token lexer.Token, found bool) {
+				val, ok := l.ReadUntil([]rune{' ', '\t', '\n'})
+				if !ok {
+					return Token{}, false
+				}
+				return Token{SPACE, val}, true
+			},
+		},
+	}
+
+	t.Run("reads tokens one by one", func(t *testing.T) {
+		lex := lexer.NewStreamLexer(strings.NewReader("12+\n3.5"), ops)
+		want := []lexer.Positioned{
+			{Token{NUM, "12"}, lexer.Position{0, 1, 1}, lexer.Position{2, 1, 3}, nil},
+			{Token{ADD, ""}, lexer.Position{2, 1, 3}, lexer.Position{3, 1, 4}, nil},
+			{Token{SPACE, "\n"}, lexer.Position{3, 1, 4}, lexer.Position{4, 2, 1}, nil},
+			{Token{NUM, "3.5"}, lexer.Position{4, 2, 1}, lexer.Position{7, 2, 4}, nil},
+		}
+		for _, w := range want {
+			got, err := lex.Next()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, w) {
+				t.Fatalf("Next() = %v, want %v", got, w)
+			}
+		}
+		if _, err := lex.Next(); err != io.EOF {
+			t.Fatalf("\nexpected: %v\nactual  : %v", io.EOF, err)
+		}
+	})
+
+	t.Run("long input", func(t *testing.T) {
+		// 9MB of input, which would take 36MB if the Lexer kept all of its runes
+		const repeat = 1000000
+		lex := lexer.NewStreamLexer(&repeatReader{text: "123.45 + ", count: repeat}, ops)
+		var before, after runtime.MemStats
+		runtime.GC()
+		runtime.ReadMemStats(&before)
+		count := 0
+		for {
+			_, err := lex.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			count++
+		}
+		if count != 4*repeat {
+			t.Fatalf("\nexpected: %v\nactual  : %v", 4*repeat, count)
+		}
+		runtime.GC()
+		runtime.ReadMemStats(&after)
+		if after.HeapAlloc > before.HeapAlloc+1<<20 {
+			t.Fatalf("\nexpected: less than 1MB kept by the Lexer\nactual  : %v bytes", after.HeapAlloc-before.HeapAlloc)
+		}
+		runtime.KeepAlive(lex)
+	})
+
+	t.Run("unknown symbol", func(t *testing.T) {
+		lex := lexer.NewStreamLexer(strings.NewReader("1+\n?"), ops)
+		var err error
+		for err == nil {
+			_, err = lex.Next()
+		}
+		expected := lexer.UnknownSymbolError{'?', lexer.Position{3, 2, 1}}
+		if err != expected {
+			t.Fatalf("\nexpected: %v\nactual  : %v", expected, err)
+		}
+	})
+
+	t.Run("reader error", func(t *testing.T) {
+		readErr := errors.New("connection lost")
+		tests := []struct {
+			input string
+			want  []lexer.Token
+		}{
+			// "12" may be the beginning of a longer number, so it is not returned as a complete Token
+			{"12", nil},
+			{"1+2", []lexer.Token{Token{NUM, "1"}, Token{ADD, ""}}},
+			{"1+", []lexer.Token{Token{NUM, "1"}, Token{ADD, ""}}},
+		}
+		for _, tt := range tests {
+			lex := lexer.NewStreamLexer(io.MultiReader(strings.NewReader(tt.input), iotest.ErrReader(readErr)), ops)
+			var tokens []lexer.Token
+			for {
+				p, err := lex.Next()
+				if err != nil {
+					if err != readErr {
+						t.Fatalf("\nexpected: %v\nactual  : %v", readErr, err)
+					}
+					break
+				}
+				tokens = append(tokens, p.Token)
+			}
+			if !reflect.DeepEqual(tokens, tt.want) {
+				t.Fatalf("Next() = %v, want %v", tokens, tt.want)
+			}
+		}
+	})
+}
+
+func TestLongestMatch(t *testing.T) {
+	const (
+		POW2 = iota + 100
+		LT
+		LTE
+		ASSIGN
+		EQ
+		IF
+		IDENT
+	)
+	stringMatcher := func(str string, tokenType int) lexer.MatcherFunc {
+		return func(l *lexer.Lexer) (lexer.Token, bool) {
+			if !l.ReadString(str) {
+				return Token{}, false
+			}
+			return Token{tokenType, str}, true
+		}
+	}
+	ops := lexer.Options{
+		// shorter operators come first, which would break them without LongestMatch
+		Tokens: []int{MUL, POW2, LT, LTE, ASSIGN, EQ, IF, IDENT, SPACE},
+		Matchers: map[int]lexer.MatcherFunc{
+			MUL:    stringMatcher("*", MUL),
+			POW2:   stringMatcher("**", POW2),
+			LT:     stringMatcher("<", LT),
+			LTE:    stringMatcher("<=", LTE),
+			ASSIGN: stringMatcher("=", ASSIGN),
+			EQ:     stringMatcher("==", EQ),
+			IF:     stringMatcher("if", IF),
+			IDENT: func(l *lexer.Lexer) (lexer.Token, bool) {
+				val, ok := l.ReadIdentifier()
+				if !ok {
+					return Token{}, false
+				}
+				return Token{IDENT, val}, true
+			},
+			SPACE: func(l *lexer.Lexer) (token lexer.Token, found bool) {
+				val, ok := l.ReadUntil([]rune{' '})
+				if !ok {
+					return Token{}, false
+				}
+				return Token{SPACE, val}, true
+			},
+		},
+		LongestMatch: true,
+	}
+
+	tests := []struct {
+		input string
+		want  []lexer.Token
+	}{
+		{"***", []lexer.Token{Token{POW2, "**"}, Token{MUL, "*"}}},
+		{"<<=", []lexer.Token{Token{LT, "<"}, Token{LTE, "<="}}},
+		{"===", []lexer.Token{Token{EQ, "=="}, Token{ASSIGN, "="}}},
+		// same length: IF comes before IDENT in Tokens
+		{"if iffy", []lexer.Token{Token{IF, "if"}, Token{SPACE, " "}, Token{IDENT, "iffy"}}},
+	}
+
+	lex := lexer.NewLexer(ops)
+	for _, tt := range tests {
+		testName := fmt.Sprint("Tokenizing", tt.input)
+		t.Run(testName, func(t *testing.T) {
+			got, err := lex.Lex(tt.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Tokenize() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("first match by default", func(t *testing.T) {
+		ops.LongestMatch = false
+		lex := lexer.NewLexer(ops)
+		got, err := lex.Lex("**<=")
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []lexer.Token{Token{MUL, "*"}, Token{MUL, "*"}, Token{LT, "<"}, Token{ASSIGN, "="}}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Tokenize() = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("unknown symbol", func(t *testing.T) {
+		_, err := lex.Lex("*?")
+		expected := lexer.UnknownSymbolError{'?', lexer.Position{1, 1, 2}}
+		if err != expected {
+			t.Fatalf("\nexpected: %v\nactual  : %v", expected, err)
+		}
+	})
+}
+
+func TestRecover(t *testing.T) {
+	ops := lexer.Options{
+		Tokens: []int{NUM, ADD, SPACE},
+		Matchers: map[int]lexer.MatcherFunc{
+			ADD: createOneCharMatcher('+', ADD),
+			NUM: func(l *lexer.Lexer) (token lexer.Token, found bool) {
+				val, ok := l.ReadInt()
+				if !ok {
+					return Token{}, false
+				}
+				return Token{NUM, val}, true
+			},
+			SPACE: func(l *lexer.Lexer) (token lexer.Token, found bool) {
+				val, ok := l.ReadUntil([]rune{' ', '\n'})
+				if !ok {
+					return Token{}, false
+				}
+				return Token{SPACE, val}, true
+			},
+		},
+		Recover: true,
+	}
+
+	t.Run("emits error tokens and continues", func(t *testing.T) {
+		lex := lexer.NewLexer(ops)
+		got, err := lex.LexPositioned("1+?!2\n$")
+		want := []lexer.Positioned{
+			{Token{NUM, "1"}, lexer.Position{0, 1, 1}, lexer.Position{1, 1, 2}, nil},
+			{Token{ADD, ""}, lexer.Position{1, 1, 2}, lexer.Position{2, 1, 3}, nil},
+			{lexer.ErrorToken{"?!"}, lexer.Position{2, 1, 3}, lexer.Position{4, 1, 5}, nil},
+			{Token{NUM, "2"}, lexer.Position{4, 1, 5}, lexer.Position{5, 1, 6}, nil},
+			{Token{SPACE, "\n"}, lexer.Position{5, 1, 6}, lexer.Position{6, 2, 1}, nil},
+			{lexer.ErrorToken{"$"}, lexer.Position{6, 2, 1}, lexer.Position{7, 2, 2}, nil},
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("LexPositioned() = %v, want %v", got, want)
+		}
+		wantErr := lexer.UnknownSymbolErrors{
+			{'?', lexer.Position{2, 1, 3}},
+			{'$', lexer.Position{6, 2, 1}},
+		}
+		if !reflect.DeepEqual(err, wantErr) {
+			t.Fatalf("\nexpected: %v\nactual  : %v", wantErr, err)
+		}
+	})
+
+	t.Run("no errors", func(t *testing.T) {
+		lex := lexer.NewLexer(ops)
+		got, err := lex.Lex("1 + 2")
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []lexer.Token{Token{NUM, "1"}, Token{SPACE, " "}, Token{ADD, ""}, Token{SPACE, " "}, Token{NUM, "2"}}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("Lex() = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("stream", func(t *testing.T) {
+		lex := lexer.NewStreamLexer(strings.NewReader("1??+"), ops)
+		want := []lexer.Token{Token{NUM, "1"}, lexer.ErrorToken{"??"}, Token{ADD, ""}}
+		var tokens []lexer.Token
+		var errs []error
+		for {
+			p, err := lex.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				errs = append(errs, err)
+			}
+			tokens = append(tokens, p.Token)
+		}
+		if !reflect.DeepEqual(tokens, want) {
+			t.Fatalf("Next() = %v, want %v", tokens, want)
+		}
+		wantErrs := []error{lexer.UnknownSymbolError{'?', lexer.Position{1, 1, 2}}}
+		if !reflect.DeepEqual(errs, wantErrs) {
+			t.Fatalf("\nexpected: %v\nactual  : %v", wantErrs, errs)
+		}
+	})
+}
+
+func TestStates(t *testing.T) {
+	const (
+		QUOTE = iota + 100
+		TEXT
+		L_BRACKET
+		R_BRACKET
+		UNIT
+	)
+	ops := lexer.Options{
+		Tokens: []int{NUM, ADD, SPACE, QUOTE, L_BRACKET},
+		Matchers: map[int]lexer.MatcherFunc{
+			NUM: func(l *lexer.Lexer) (token lexer.Token, found bool) {
+				val, ok := l.ReadIntOrFloat()
+				if !ok {
+					return Token{}, false
+				}
+				return Token{NUM, val}, true
+			},
+			ADD:   createOneCharMatcher('+', ADD),
+			SPACE: createOneCharMatcher(' ', SPACE),
+			QUOTE: func(l *lexer.Lexer) (token lexer.Token, found bool) {
+				if !l.ReadChar('"') {
+					return Token{}, false
+				}
+				l.PushState("string")
+				return Token{QUOTE, ""}, true
+			},
+			L_BRACKET: func(l *lexer.Lexer) (token lexer.Token, found bool) {
+				if !l.ReadChar('[') {
+					return Token{}, false
+				}
+				l.PushState("unit")
+				return Token{L_BRACKET, ""}, true
+			},
+		},
+		States: map[string]lexer.State{
+			"string": {
+				Tokens: []int{QUOTE, TEXT},
+				Matchers: map[int]lexer.MatcherFunc{
+					QUOTE: func(l *lexer.Lexer) (token lexer.Token, found bool) {
+						if !l.ReadChar('"') {
+							return Token{}, false
+						}
+						l.PopState()
+						return Token{QUOTE, ""}, true
+					},
+					TEXT: func(l *lexer.Lexer) (token lexer.Token, found bool) {
+						sb := strings.Builder{}
+						for {
+							ch, done := l.ReadNext()
+							if done {
+								break
+							}
+							if ch == '"' {
+								l.Unread()
+								break
+							}
+							sb.WriteRune(ch)
+						}
+						return Token{TEXT, sb.String()}, sb.Len() > 0
+					},
+				},
+			},
+			"unit": {
+				Tokens: []int{UNIT, R_BRACKET},
+				Matchers: map[int]lexer.MatcherFunc{
+					UNIT: func(l *lexer.Lexer) (token lexer.Token, found bool) {
+						val, ok := l.ReadIdentifier()
+						if !ok {
+							return Token{}, false
+						}
+						return Token{UNIT, val}, true
+					},
+					R_BRACKET: func(l *lexer.Lexer) (token lexer.Token, found bool) {
+						if !l.ReadChar(']') {
+							return Token{}, false
+						}
+						l.PopState()
+						return Token{R_BRACKET, ""}, true
+					},
+				},
+			},
+		},
+	}
+
+	tests := []struct {
+		input string
+		want  []lexer.Token
+	}{
+		{"5 [kg]", []lexer.Token{Token{NUM, "5"}, Token{SPACE, ""}, Token{L_BRACKET, ""}, Token{UNIT, "kg"}, Token{R_BRACKET, ""}}},
+		{`"1 + [2]"+3`, []lexer.Token{Token{QUOTE, ""}, Token{TEXT, "1 + [2]"}, Token{QUOTE, ""}, Token{ADD, ""}, Token{NUM, "3"}}},
+		{`""`, []lexer.Token{Token{QUOTE, ""}, Token{QUOTE, ""}}},
+	}
+
+	lex := lexer.NewLexer(ops)
+	for _, tt := range tests {
+		testName := fmt.Sprint("Tokenizing", tt.input)
+		t.Run(testName, func(t *testing.T) {
+			got, err := lex.Lex(tt.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Lex() = %v, want %v", got, tt.want)
+			}
+			if lex.State() != lexer.DefaultState {
+				t.Fatalf("\nexpected: %q\nactual  : %q", lexer.DefaultState, lex.State())
+			}
+		})
+	}
+
+	t.Run("tokens of other states are unknown", func(t *testing.T) {
+		_, err := lex.Lex("5 kg")
+		expected := lexer.UnknownSymbolError{'k', lexer.Position{2, 1, 3}}
+		if err != expected {
+			t.Fatalf("\nexpected: %v\nactual  : %v", expected, err)
+		}
+	})
+
+	t.Run("state is left unclosed", func(t *testing.T) {
+		_, err := lex.Lex("5 [kg")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if lex.State() != "unit" {
+			t.Fatalf("\nexpected: %q\nactual  : %q", "unit", lex.State())
+		}
+	})
+
+	t.Run("state change is undone when matcher doesn't find a token", func(t *testing.T) {
+		lex := lexer.NewLexer(lexer.Options{
+			Tokens: []int{L_BRACKET, ADD},
+			Matchers: map[int]lexer.MatcherFunc{
+				L_BRACKET: func(l *lexer.Lexer) (token lexer.Token, found bool) {
+					l.PushState("unit")
+					return Token{}, false
+				},
+				ADD: createOneCharMatcher('+', ADD),
+			},
+			States: ops.States,
+		})
+		got, err := lex.Lex("++")
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []lexer.Token{Token{ADD, ""}, Token{ADD, ""}}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("Lex() = %v, want %v", got, want)
+		}
+	})
+}
+
+func TestTrivia(t *testing.T) {
+	const (
+		COMMENT = iota + 100
+		BLOCK_COMMENT
+	)
+	newToken := func(tokenType int) func(string) lexer.Token {
+		return func(text string) lexer.Token {
+			return Token{tokenType, text}
+		}
+	}
+	ops := lexer.Table(
+		lexer.Rule{NUM, lexer.RegexpMatcher(`[0-9]+`, newToken(NUM))},
+		lexer.Rule{COMMENT, lexer.LineCommentMatcher("#", newToken(COMMENT))},
+		lexer.Rule{SPACE, lexer.WhitespaceMatcher(newToken(SPACE))},
+		// block comments must come before DIV, because both start with "/"
+		lexer.Rule{BLOCK_COMMENT, lexer.BlockCommentMatcher("/*", "*/", newToken(COMMENT))},
+		lexer.Rule{DIV, lexer.LiteralMatcher("/", Token{DIV, "/"})},
+	)
+	ops.Trivia = []int{SPACE, COMMENT, BLOCK_COMMENT}
+
+	t.Run("attaches trivia to the following token", func(t *testing.T) {
+		lex := lexer.NewLexer(ops)
+		got, err := lex.LexPositioned("8 /* half */ / 2 # total\n")
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []lexer.Positioned{
+			{Token{NUM, "8"}, lexer.Position{0, 1, 1}, lexer.Position{1, 1, 2}, nil},
+			{Token{DIV, "/"}, lexer.Position{13, 1, 14}, lexer.Position{14, 1, 15}, []lexer.Positioned{
+				{Token{SPACE, " "}, lexer.Position{1, 1, 2}, lexer.Position{2, 1, 3}, nil},
+				{Token{COMMENT, "/* half */"}, lexer.Position{2, 1, 3}, lexer.Position{12, 1, 13}, nil},
+				{Token{SPACE, " "}, lexer.Position{12, 1, 13}, lexer.Position{13, 1, 14}, nil},
+			}},
+			{Token{NUM, "2"}, lexer.Position{15, 1, 16}, lexer.Position{16, 1, 17}, []lexer.Positioned{
+				{Token{SPACE, " "}, lexer.Position{14, 1, 15}, lexer.Position{15, 1, 16}, nil},
+			}},
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("LexPositioned() = %v, want %v", got, want)
+		}
+		wantTrailing := []lexer.Positioned{
+			{Token{SPACE, " "}, lexer.Position{16, 1, 17}, lexer.Position{17, 1, 18}, nil},
+			{Token{COMMENT, "# total"}, lexer.Position{17, 1, 18}, lexer.Position{24, 1, 25}, nil},
+			{Token{SPACE, "\n"}, lexer.Position{24, 1, 25}, lexer.Position{25, 2, 1}, nil},
+		}
+		if !reflect.DeepEqual(lex.TrailingTrivia(), wantTrailing) {
+			t.Fatalf("TrailingTrivia() = %v, want %v", lex.TrailingTrivia(), wantTrailing)
+		}
+	})
+
+	t.Run("unclosed block comment", func(t *testing.T) {
+		lex := lexer.NewLexer(ops)
+		// "/" is found instead
+		_, err := lex.Lex("8/*2")
+		expected := lexer.UnknownSymbolError{'*', lexer.Position{2, 1, 3}}
+		if err != expected {
+			t.Fatalf("\nexpected: %v\nactual  : %v", expected, err)
+		}
+	})
+
+	t.Run("stream", func(t *testing.T) {
+		lex := lexer.NewStreamLexer(strings.NewReader("1 # one\n2"), ops)
+		var tokens []lexer.Token
+		var trivia int
+		for {
+			p, err := lex.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			tokens = append(tokens, p.Token)
+			trivia += len(p.Trivia)
+		}
+		want := []lexer.Token{Token{NUM, "1"}, Token{NUM, "2"}}
+		if !reflect.DeepEqual(tokens, want) {
+			t.Fatalf("Next() = %v, want %v", tokens, want)
+		}
+		if trivia != 3 {
+			t.Fatalf("\nexpected: %v\nactual  : %v", 3, trivia)
+		}
+	})
+}
+
+func TestFirstRunes(t *testing.T) {
+	const (
+		IDENT = iota + 100
+	)
+	calls := map[int]int{}
+	counted := func(tokenType int, matcher lexer.MatcherFunc) lexer.MatcherFunc {
+		return func(l *lexer.Lexer) (lexer.Token, bool) {
+			calls[tokenType]++
+			return matcher(l)
+		}
+	}
+	lex := lexer.NewLexer(lexer.Options{
+		Tokens: []int{NUM, ADD, SUB, IDENT},
+		Matchers: map[int]lexer.MatcherFunc{
+			NUM: counted(NUM, func(l *lexer.Lexer) (lexer.Token, bool) {
+				val, ok := l.ReadInt()
+				if !ok {
+					return Token{}, false
+				}
+				return Token{NUM, val}, true
+			}),
+			ADD: counted(ADD, createOneCharMatcher('+', ADD)),
+			SUB: counted(SUB, createOneCharMatcher('-', SUB)),
+			IDENT: counted(IDENT, func(l *lexer.Lexer) (lexer.Token, bool) {
+				val, ok := l.ReadIdentifier()
+				if !ok {
+					return Token{}, false
+				}
+				return Token{IDENT, val}, true
+			}),
+		},
+		FirstRunes: map[int]string{
+			NUM: "0123456789",
+			ADD: "+",
+			SUB: "-",
+		},
+	})
+
+	got, err := lex.Lex("1+x-2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []lexer.Token{Token{NUM, "1"}, Token{ADD, ""}, Token{IDENT, "x"}, Token{SUB, ""}, Token{NUM, "2"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Lex() = %v, want %v", got, want)
+	}
+	// IDENT has no hint, so it is tried for every rune, but never before the hinted Tokens that find it
+	wantCalls := map[int]int{NUM: 2, ADD: 1, SUB: 1, IDENT: 1}
+	if !reflect.DeepEqual(calls, wantCalls) {
+		t.Fatalf("\nexpected: %v\nactual  : %v", wantCalls, calls)
+	}
+}
+
+func TestPatternTable(t *testing.T) {
+	const (
+		KEYWORD = iota + 100
+		IDENT
+		STRING
+	)
+	newToken := func(tokenType int) func(string) lexer.Token {
+		return func(text string) lexer.Token {
+			return Token{tokenType, text}
+		}
+	}
+	lex := lexer.NewLexer(lexer.Table(
+		lexer.Rule{NUM, lexer.RegexpMatcher(`[0-9]+(\.[0-9]+)?`, newToken(NUM))},
+		lexer.Rule{KEYWORD, lexer.KeywordMatcher([]string{"if", "else"}, newToken(KEYWORD))},
+		lexer.Rule{IDENT, lexer.RegexpMatcher(`[\pL_][\pL\pN_]*`, newToken(IDENT))},
+		lexer.Rule{STRING, lexer.RegexpMatcher(`"[^"]*"`, newToken(STRING))},
+		lexer.Rule{POW, lexer.LiteralMatcher("**", Token{POW, "**"})},
+		lexer.Rule{MUL, lexer.LiteralMatcher("*", Token{MUL, "*"})},
+		lexer.Rule{SPACE, lexer.RegexpMatcher(`\s+`, newToken(SPACE))},
+	))
+
+	tests := []struct {
+		input string
+		want  []lexer.Token
+		err   error
+	}{
+		{"12.5**2", []lexer.Token{Token{NUM, "12.5"}, Token{POW, "**"}, Token{NUM, "2"}}, nil},
+		{"12.", nil, lexer.UnknownSymbolError{'.', lexer.Position{2, 1, 3}}},
+		{"if iffy else", []lexer.Token{Token{KEYWORD, "if"}, Token{SPACE, " "}, Token{IDENT, "iffy"}, Token{SPACE, " "}, Token{KEYWORD, "else"}}, nil},
+		{`çay*"a b"`, []lexer.Token{Token{IDENT, "çay"}, Token{MUL, "*"}, Token{STRING, `"a b"`}}, nil},
+		{`"unterminated`, nil, lexer.UnknownSymbolError{'"', lexer.Position{0, 1, 1}}},
+	}
+
+	for _, tt := range tests {
+		testName := fmt.Sprint("Tokenizing", tt.input)
+		t.Run(testName, func(t *testing.T) {
+			got, err := lex.Lex(tt.input)
+			if err != tt.err {
+				t.Fatalf("\nexpected: %v\nactual  : %v", tt.err, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Tokenize() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadNumber(t *testing.T) {
+	tests := []struct {
+		input   string
+		literal string
+		err     error
+	}{
+		{"123", "123", nil},
+		{"1.5+", "1.5", nil},
+		{".5", ".5", nil},
+		{"1.", "1.", nil},
+		{"1.+2", "1.", nil},
+		{"1.e5", "1.e5", nil},
+		{"1._5", "1._5", lexer.ErrInvalidDigitSeparator},
+		{"1.5_", "1.5_", lexer.ErrInvalidDigitSeparator},
+		{"1e-3*", "1e-3", nil},
+		{"2.5E+10", "2.5E+10", nil},
+		{"0xFFx", "0xFFx", lexer.ErrInvalidDigit},
+		{"0xFF+1", "0xFF", nil},
+		{"0b1010", "0b1010", nil},
+		{"0o17", "0o17", nil},
+		{"0", "0", nil},
+		{"09", "09", nil},
+		{"1_000_000", "1_000_000", nil},
+		{"0x_FF_FF", "0x_FF_FF", nil},
+		{"1e", "1e", lexer.ErrExponentHasNoDigits},
+		{"1e+", "1e+", lexer.ErrExponentHasNoDigits},
+		{"0x", "0x", lexer.ErrNumberHasNoDigits},
+		{"0b12", "0b12", lexer.ErrInvalidDigit},
+		{"1__0", "1__0", lexer.ErrInvalidDigitSeparator},
+		{"1_", "1_", lexer.ErrInvalidDigitSeparator},
+		{"0x_", "0x_", lexer.ErrNumberHasNoDigits},
+		{"0xF_", "0xF_", lexer.ErrInvalidDigitSeparator},
+		{"_1", "", nil},
+		{".", "", nil},
+		{"._5", "", nil},
+		{".e5", "", nil},
+		{"x", "", nil},
+	}
+
+	for _, tt := range tests {
+		testName := fmt.Sprint("Reading ", tt.input)
+		t.Run(testName, func(t *testing.T) {
+			var literal string
+			var err error
+			// only the number in the beginning of the input is checked
+			read := false
+			lex := lexer.NewLexer(lexer.Options{
+				Tokens: []int{NUM},
+				Matchers: map[int]lexer.MatcherFunc{
+					NUM: func(l *lexer.Lexer) (lexer.Token, bool) {
+						if read {
+							return Token{}, false
+						}
+						read = true
+						literal, err = l.ReadNumber()
+						return Token{NUM, literal}, literal != ""
+					},
+				},
+			})
+			lex.Lex(tt.input)
+			if literal != tt.literal || err != tt.err {
+				t.Fatalf("\nexpected: %q, %v\nactual  : %q, %v", tt.literal, tt.err, literal, err)
+			}
+		})
+	}
+}
+
+// benchmarkOptions creates a grammar of many operators and keywords, with or without FirstRunes hints
+func benchmarkOptions(withHints bool) lexer.Options {
+	const IDENT = 1000
+	ops := lexer.Options{
+		Tokens:     []int{},
+		Matchers:   map[int]lexer.MatcherFunc{},
+		FirstRunes: map[int]string{},
+	}
+	symbols := []string{"==", "!=", "<=", ">=", "&&", "||", "<<", ">>", "+", "-", "*", "/", "%", "^", "<", ">", "!", "(", ")", ","}
+	for i, symbol := range symbols {
+		tokenType := 100 + i
+		ops.Tokens = append(ops.Tokens, tokenType)
+		ops.Matchers[tokenType] = lexer.LiteralMatcher(symbol, Token{tokenType, symbol})
+		ops.FirstRunes[tokenType] = symbol[:1]
+	}
+	ops.Tokens = append(ops.Tokens, NUM, SPACE, IDENT)
+	ops.Matchers[NUM] = func(l *lexer.Lexer) (lexer.Token, bool) {
+		val, err := l.ReadNumber()
+		return Token{NUM, val}, val != "" && err == nil
+	}
+	ops.FirstRunes[NUM] = "0123456789."
+	ops.Matchers[SPACE] = func(l *lexer.Lexer) (lexer.Token, bool) {
+		val, ok := l.ReadUntil([]rune{' '})
+		return Token{SPACE, val}, ok
+	}
+	ops.FirstRunes[SPACE] = " "
+	ops.Matchers[IDENT] = func(l *lexer.Lexer) (lexer.Token, bool) {
+		val, ok := l.ReadIdentifier()
+		return Token{IDENT, val}, ok
+	}
+	if !withHints {
+		ops.FirstRunes = nil
+	}
+	return ops
+}
+
+var benchmarkInput = strings.Repeat("price * (qty + 1) - discount / 2.5 >= total && 10 % 3 != 0 || ", 1000) + "1"
+
+func benchmarkLex(b *testing.B, withHints bool) {
+	lex := lexer.NewLexer(benchmarkOptions(withHints))
+	b.SetBytes(int64(len(benchmarkInput)))
+	for i := 0; i < b.N; i++ {
+		if _, err := lex.Lex(benchmarkInput); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkLex(b *testing.B) {
+	benchmarkLex(b, false)
+}
+
+func BenchmarkLexWithFirstRunes(b *testing.B) {
+	benchmarkLex(b, true)
+}
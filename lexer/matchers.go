@@ -0,0 +1,169 @@
+package lexer
+
+import (
+	"io"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Rule pairs a TokenType with the MatcherFunc that finds it. See #Table
+type Rule struct {
+	TokenType int
+	Matcher   MatcherFunc
+}
+
+// Table creates the Options from the rules, so that the whole Lexer can be declared as a table of patterns.
+// Order of the rules is the order of `Options.Tokens`
+// example:
+// lexer.NewLexer(lexer.Table(lexer.Rule{NUM, lexer.RegexpMatcher(`[0-9]+`, newNumToken)}, lexer.Rule{ADD, addMatcher}))
+func Table(rules ...Rule) Options {
+	ops := Options{
+		Tokens:   make([]int, 0, len(rules)),
+		Matchers: make(map[int]MatcherFunc, len(rules)),
+	}
+	for _, rule := range rules {
+		ops.Tokens = append(ops.Tokens, rule.TokenType)
+		ops.Matchers[rule.TokenType] = rule.Matcher
+	}
+	return ops
+}
+
+// RegexpMatcher creates a MatcherFunc that finds the text matching the regular expression at the current position,
+// and creates the Token from it with `create`. Empty matches are ignored.
+// Panics if the pattern cannot be compiled
+func RegexpMatcher(pattern string, create func(text string) Token) MatcherFunc {
+	// \A anchors the pattern at the current position
+	re := regexp.MustCompile(`\A(?:` + pattern + `)`)
+	return func(l *Lexer) (Token, bool) {
+		mark := l.Mark()
+		// regexp can read more runes than it matches, so the Lexer goes back, and reads only the matched part
+		loc := re.FindReaderIndex(runeReader{l})
+		l.Reset(mark)
+		if loc == nil || loc[1] == 0 {
+			return nil, false
+		}
+		sb := strings.Builder{}
+		for sb.Len() < loc[1] {
+			ch, _ := l.ReadNext()
+			sb.WriteRune(ch)
+		}
+		return create(sb.String()), true
+	}
+}
+
+// runeReader reads the runes of the Lexer starting from the current position
+type runeReader struct {
+	l *Lexer
+}
+
+func (r runeReader) ReadRune() (rune, int, error) {
+	ch, done := r.l.ReadNext()
+	if done {
+		return 0, 0, io.EOF
+	}
+	return ch, utf8.RuneLen(ch), nil
+}
+
+// LiteralMatcher creates a MatcherFunc that finds the exact `literal` string, and returns the given Token
+func LiteralMatcher(literal string, token Token) MatcherFunc {
+	return func(l *Lexer) (Token, bool) {
+		if !l.ReadString(literal) {
+			return nil, false
+		}
+		return token, true
+	}
+}
+
+// KeywordMatcher creates a MatcherFunc that finds one of the keywords, and creates the Token from it with `create`.
+// Keywords are matched as whole identifiers, so "if" is not found in "iffy"
+func KeywordMatcher(keywords []string, create func(keyword string) Token) MatcherFunc {
+	set := make(map[string]bool, len(keywords))
+	for _, keyword := range keywords {
+		set[keyword] = true
+	}
+	return func(l *Lexer) (Token, bool) {
+		mark := l.Mark()
+		word, ok := l.ReadIdentifier()
+		if !ok || !set[word] {
+			l.Reset(mark)
+			return nil, false
+		}
+		return create(word), true
+	}
+}
+
+// WhitespaceMatcher creates a MatcherFunc that finds a run of whitespace runes, and creates the Token from it with `create`.
+// It is meant to be used with Options.Trivia
+func WhitespaceMatcher(create func(text string) Token) MatcherFunc {
+	return func(l *Lexer) (Token, bool) {
+		sb := strings.Builder{}
+		for {
+			ch, done := l.ReadNext()
+			if done {
+				break
+			}
+			if !unicode.IsSpace(ch) {
+				l.Unread()
+				break
+			}
+			sb.WriteRune(ch)
+		}
+		if sb.Len() == 0 {
+			return nil, false
+		}
+		return create(sb.String()), true
+	}
+}
+
+// LineCommentMatcher creates a MatcherFunc that finds a comment from the `prefix` until the end of the line,
+// and creates the Token from it with `create`. The line break is not part of the comment.
+// It is meant to be used with Options.Trivia
+// example: LineCommentMatcher("#", newCommentToken) finds "# price of 1 item"
+func LineCommentMatcher(prefix string, create func(text string) Token) MatcherFunc {
+	return func(l *Lexer) (Token, bool) {
+		if !l.ReadString(prefix) {
+			return nil, false
+		}
+		sb := strings.Builder{}
+		sb.WriteString(prefix)
+		for {
+			ch, done := l.ReadNext()
+			if done {
+				break
+			}
+			if ch == '\n' {
+				l.Unread()
+				break
+			}
+			sb.WriteRune(ch)
+		}
+		return create(sb.String()), true
+	}
+}
+
+// BlockCommentMatcher creates a MatcherFunc that finds a comment between `open` and `close`,
+// and creates the Token from it with `create`. Comments that are not closed are not found.
+// It is meant to be used with Options.Trivia.
+// Put it before the Tokens that start the same way, like "/" for "/*", or use LongestMatch
+func BlockCommentMatcher(open, close string, create func(text string) Token) MatcherFunc {
+	return func(l *Lexer) (Token, bool) {
+		mark := l.Mark()
+		if !l.ReadString(open) {
+			return nil, false
+		}
+		sb := strings.Builder{}
+		sb.WriteString(open)
+		for !l.ReadString(close) {
+			ch, done := l.ReadNext()
+			if done {
+				l.Reset(mark)
+				return nil, false
+			}
+			sb.WriteRune(ch)
+		}
+		sb.WriteString(close)
+		return create(sb.String()), true
+	}
+}
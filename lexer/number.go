@@ -0,0 +1,163 @@
+package lexer
+
+import (
+	"errors"
+	"strings"
+)
+
+var ErrExponentHasNoDigits = errors.New("exponent has no digits")
+var ErrNumberHasNoDigits = errors.New("number has no digits after the base prefix")
+var ErrInvalidDigit = errors.New("invalid digit for the base of the number")
+var ErrInvalidDigitSeparator = errors.New("'_' must separate successive digits")
+
+// ReadNumber tries to read a numeric literal. Supported literals are:
+// - integers and floats: "12", "1.5", ".5", "5."
+// - floats with exponent: "1e-3", "2.5E+10", "1.e3"
+// - binary, octal and hexadecimal integers: "0b1010", "0o17", "0xFF"
+// - any of them with '_' digit separators: "1_000_000", "0xFF_FF"
+//
+// Returns an empty string if there is no number in the current position.
+// If the number is malformed (e.g. "1e", "0x", "0b12", "1__0", "1._5"), the malformed part is read and returned with the error,
+// so that it can be reported with its position
+func (l *Lexer) ReadNumber() (string, error) {
+	mark := l.Mark()
+	first, done := l.ReadNext()
+	if done {
+		return "", nil
+	}
+	if first == '0' {
+		if prefix, base, ok := l.readRadixPrefix(); ok {
+			return l.readRadixDigits("0"+prefix, base)
+		}
+	}
+	l.Reset(mark)
+
+	sb := strings.Builder{}
+	var err error
+	setErr := func(e error) {
+		if err == nil {
+			err = e
+		}
+	}
+
+	intPart, _ := l.ReadUntil(decimalDigits)
+	sb.WriteString(intPart)
+	if intPart != "" {
+		setErr(checkDigitSeparators(intPart))
+	}
+
+	// fraction, like in Go the fraction can be empty after the integer part, e.g. "5." or "1.e3",
+	// but a '.' without the integer part is part of the number only if it is followed by a digit
+	beforeDot := l.Mark()
+	if l.ReadChar('.') {
+		fraction, _ := l.ReadUntil(decimalDigits)
+		if intPart == "" && (fraction == "" || fraction[0] == '_') {
+			l.Reset(beforeDot)
+		} else {
+			sb.WriteString(".")
+			sb.WriteString(fraction)
+			if fraction != "" {
+				setErr(checkDigitSeparators(fraction))
+			}
+		}
+	}
+	if sb.Len() == 0 || strings.HasPrefix(sb.String(), "_") {
+		// "_" is not a number
+		l.Reset(mark)
+		return "", nil
+	}
+
+	// exponent
+	if e, ok := l.readOneOf("eE"); ok {
+		sb.WriteRune(e)
+		if sign, ok := l.readOneOf("+-"); ok {
+			sb.WriteRune(sign)
+		}
+		exponent, _ := l.ReadUntil(decimalDigits)
+		sb.WriteString(exponent)
+		if exponent == "" {
+			setErr(ErrExponentHasNoDigits)
+		} else {
+			setErr(checkDigitSeparators(exponent))
+		}
+	}
+	return sb.String(), err
+}
+
+var decimalDigits = []rune("0123456789_")
+
+// readOneOf reads the next rune if it is one of the `runes`
+func (l *Lexer) readOneOf(runes string) (rune, bool) {
+	ch, done := l.ReadNext()
+	if done {
+		return ch, false
+	}
+	if !strings.ContainsRune(runes, ch) {
+		l.Unread()
+		return ch, false
+	}
+	return ch, true
+}
+
+// readRadixPrefix reads the second rune of "0x", "0o" or "0b" prefixes, and returns it with the base of the number
+func (l *Lexer) readRadixPrefix() (string, int, bool) {
+	ch, ok := l.readOneOf("xXoObB")
+	if !ok {
+		return "", 0, false
+	}
+	switch ch {
+	case 'x', 'X':
+		return string(ch), 16, true
+	case 'o', 'O':
+		return string(ch), 8, true
+	}
+	return string(ch), 2, true
+}
+
+// readRadixDigits reads all the letters, digits and '_' after the radix prefix, and checks if they are valid digits for the base
+func (l *Lexer) readRadixDigits(prefix string, base int) (string, error) {
+	sb := strings.Builder{}
+	for {
+		ch, done := l.ReadNext()
+		if done {
+			break
+		}
+		if ch != '_' && !isIdentifierStart(ch) && !isBetween(ch, '0', '9') {
+			l.Unread()
+			break
+		}
+		sb.WriteRune(ch)
+	}
+	digits := sb.String()
+	if strings.Trim(digits, "_") == "" {
+		return prefix + digits, ErrNumberHasNoDigits
+	}
+	for _, ch := range digits {
+		if ch != '_' && digitValue(ch) >= base {
+			return prefix + digits, ErrInvalidDigit
+		}
+	}
+	// "0x_FF" is valid, '_' can separate the prefix and the first digit
+	return prefix + digits, checkDigitSeparators("0" + digits)
+}
+
+// checkDigitSeparators checks that every '_' is between 2 digits
+func checkDigitSeparators(digits string) error {
+	if strings.HasPrefix(digits, "_") || strings.HasSuffix(digits, "_") || strings.Contains(digits, "__") {
+		return ErrInvalidDigitSeparator
+	}
+	return nil
+}
+
+// digitValue returns the value of the digit in bases up to 16. returns 16 if it is not a digit
+func digitValue(ch rune) int {
+	switch {
+	case isBetween(ch, '0', '9'):
+		return int(ch - '0')
+	case isBetween(ch, 'a', 'f'):
+		return int(ch-'a') + 10
+	case isBetween(ch, 'A', 'F'):
+		return int(ch-'A') + 10
+	}
+	return 16
+}
@@ -0,0 +1,178 @@
+package calculator
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Associativity defines in which order the operators with same precedence are grouped.
+// "8/4/2" is "(8/4)/2" because "/" is left-associative, "2^3^2" is "2^(3^2)" because "^" is right-associative
+type Associativity int
+
+const (
+	LeftAssociative Associativity = iota
+	RightAssociative
+)
+
+// OperatorSpec defines an operator that can be used in the expressions.
+//
+// Precedence defines how tightly the operator binds its operands, higher binds tighter.
+// Built-in operators use 1 for "+" and "-", 2 for "*" and "/", 3 for unary "-" and "+", 4 for "^".
+//
+// Arity is 2 for binary operators like "a % b", and 1 for prefix operators like "-a".
+// The same Symbol can be defined both as a binary and as a prefix operator.
+//
+// Fn calculates the result from the values of the operands.
+//
+// Overloads calculate the operator for other types of the operands in the typed expressions, see Calculator.EvalValue.
+// Fn is always available there as the Overload for Float operands
+type OperatorSpec struct {
+	Symbol        string
+	Precedence    int
+	Associativity Associativity
+	Arity         int
+	Fn            func(args ...float64) float64
+	Overloads     []Overload
+
+	// node creates the built-in node of the operator, e.g. AddNode for "+".
+	// OperatorNode is used when it is not set
+	node func(operands []Calculatable, startPos, endPos int) Calculatable
+}
+
+// buildNode creates the node of the operator with given operands.
+// startPos and endPos are the position of the operator in the user input
+func (op OperatorSpec) buildNode(operands []Calculatable, startPos, endPos int) Calculatable {
+	if op.node != nil {
+		return op.node(operands, startPos, endPos)
+	}
+	return OperatorNode{op.Symbol, op.Fn, operands, startPos, endPos}
+}
+
+// overloads returns the Overloads of the operator, followed by the Overload of Fn for Float operands
+func (op OperatorSpec) overloads() []Overload {
+	overloads := make([]Overload, 0, len(op.Overloads)+1)
+	overloads = append(overloads, op.Overloads...)
+	return append(overloads, floatOverload(op.Arity, op.Fn))
+}
+
+// validate panics if the OperatorSpec cannot be used by the Calculator
+func (op OperatorSpec) validate() {
+	if op.Symbol == "" {
+		panic("calculator: operator symbol cannot be empty")
+	}
+	if strings.IndexFunc(op.Symbol, isReservedRune) != -1 {
+		panic("calculator: operator " + op.Symbol + " cannot contain letters, digits, spaces, '.', ',', '_' or paracentheses")
+	}
+	if op.Arity != 1 && op.Arity != 2 {
+		panic("calculator: operator " + op.Symbol + " must have an arity of 1 or 2")
+	}
+	if op.Fn == nil {
+		panic("calculator: operator " + op.Symbol + " must have Fn")
+	}
+	for _, o := range op.Overloads {
+		if len(o.Operands) != op.Arity || o.Fn == nil {
+			panic("calculator: overloads of operator " + op.Symbol + " must have Fn and " + strconv.Itoa(op.Arity) + " operands")
+		}
+	}
+}
+
+// isReservedRune checks if rune is used by the other tokens, so it cannot be used in an operator symbol
+func isReservedRune(ch rune) bool {
+	return unicode.IsLetter(ch) || unicode.IsDigit(ch) || unicode.IsSpace(ch) || strings.ContainsRune(".,_()", ch)
+}
+
+// shouldPopBefore checks if the operator on the stack must be added to the tree before pushing the incoming operator
+func shouldPopBefore(stacked, incoming OperatorSpec) bool {
+	if stacked.Precedence == incoming.Precedence {
+		return incoming.Associativity == LeftAssociative
+	}
+	return stacked.Precedence > incoming.Precedence
+}
+
+// DefaultOperators returns the built-in BODMAS operators, which every Calculator supports.
+// Built-in operators are calculated by their own nodes (AddNode, SubNode...),
+// so to change how an operator is calculated, define a new OperatorSpec instead of modifying the Fn of these
+func DefaultOperators() []OperatorSpec {
+	return []OperatorSpec{
+		{
+			Symbol: "+", Precedence: 1, Associativity: LeftAssociative, Arity: 2,
+			Fn:        func(args ...float64) float64 { return args[0] + args[1] },
+			Overloads: []Overload{binaryIntOverload(addInt)},
+			node:      func(o []Calculatable, start, end int) Calculatable { return AddNode{o[0], o[1], start, end} },
+		},
+		{
+			Symbol: "-", Precedence: 1, Associativity: LeftAssociative, Arity: 2,
+			Fn:        func(args ...float64) float64 { return args[0] - args[1] },
+			Overloads: []Overload{binaryIntOverload(subInt)},
+			node:      func(o []Calculatable, start, end int) Calculatable { return SubNode{o[0], o[1], start, end} },
+		},
+		{
+			Symbol: "*", Precedence: 2, Associativity: LeftAssociative, Arity: 2,
+			Fn:        func(args ...float64) float64 { return args[0] * args[1] },
+			Overloads: []Overload{binaryIntOverload(mulInt)},
+			node:      func(o []Calculatable, start, end int) Calculatable { return MulNode{o[0], o[1], start, end} },
+		},
+		{
+			Symbol: "/", Precedence: 2, Associativity: LeftAssociative, Arity: 2,
+			Fn:   func(args ...float64) float64 { return args[0] / args[1] },
+			node: func(o []Calculatable, start, end int) Calculatable { return DivNode{o[0], o[1], start, end} },
+		},
+		{
+			Symbol: "-", Precedence: 3, Associativity: RightAssociative, Arity: 1,
+			Fn:        func(args ...float64) float64 { return -args[0] },
+			Overloads: []Overload{unaryIntOverload(negInt)},
+			node:      func(o []Calculatable, start, end int) Calculatable { return NegNode{o[0], start, end} },
+		},
+		// unary plus doesn't change the value, so the operand is left as it is
+		{
+			Symbol: "+", Precedence: 3, Associativity: RightAssociative, Arity: 1,
+			Fn:        func(args ...float64) float64 { return args[0] },
+			Overloads: []Overload{unaryIntOverload(func(a int64) (int64, error) { return a, nil })},
+			node:      func(o []Calculatable, start, end int) Calculatable { return o[0] },
+		},
+		{
+			Symbol: "^", Precedence: 4, Associativity: RightAssociative, Arity: 2,
+			Fn:   func(args ...float64) float64 { return math.Pow(args[0], args[1]) },
+			node: func(o []Calculatable, start, end int) Calculatable { return PowNode{o[0], o[1], start, end} },
+		},
+	}
+}
+
+// RemainderOperator returns the "%" operator, which calculates the remainder of the division with the sign of the dividend,
+// e.g. "7 % 4" is 3 and "-7 % 4" is -3. It has the precedence of "*" and "/".
+// It is not one of the DefaultOperators, so it must be added to the Calculator
+// example:
+// calculator.New(calculator.WithOperators(calculator.RemainderOperator()))
+func RemainderOperator() OperatorSpec {
+	return OperatorSpec{
+		Symbol: "%", Precedence: 2, Associativity: LeftAssociative, Arity: 2,
+		Fn:        func(args ...float64) float64 { return math.Mod(args[0], args[1]) },
+		Overloads: []Overload{binaryIntOverload(remInt)},
+		node:      func(o []Calculatable, start, end int) Calculatable { return RemNode{o[0], o[1], start, end} },
+	}
+}
+
+// operatorSymbols returns all the symbols of the operators, longest first,
+// so that the lexer finds "**" before "*"
+func operatorSymbols(operatorSets ...map[string]OperatorSpec) []string {
+	seen := map[string]bool{}
+	symbols := []string{}
+	for _, operators := range operatorSets {
+		for symbol := range operators {
+			if !seen[symbol] {
+				seen[symbol] = true
+				symbols = append(symbols, symbol)
+			}
+		}
+	}
+	sort.Slice(symbols, func(i, j int) bool {
+		if len(symbols[i]) != len(symbols[j]) {
+			return len(symbols[i]) > len(symbols[j])
+		}
+		return symbols[i] < symbols[j]
+	})
+	return symbols
+}
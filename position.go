@@ -0,0 +1,58 @@
+package calculator
+
+import "unicode/utf8"
+
+// Position is a position in the user input, counted in different units
+type Position struct {
+	// Byte is the offset in bytes, which can be used to slice the Go string
+	Byte int
+	// Rune is the offset in runes, same as EvalError.StartPos and EvalError.EndPos
+	Rune int
+	// UTF16 is the offset in UTF-16 code units, which is how browsers count the string positions
+	UTF16 int
+	// Line and Column are 1-based. Column is counted in runes
+	Line   int
+	Column int
+}
+
+// Positions returns the start and end positions of the error in the given user input.
+// EvalError keeps only the rune positions, so `input` must be the same string that was evaluated:
+// the positions are counted in `input` as it is, and for another string they point to the same runes of that string.
+// All fields of the Positions are -1 if the position of the error cannot be found,
+// or if the error doesn't fit in `input`, e.g. when it is shorter than the evaluated input
+func (e EvalError) Positions(input string) (start, end Position) {
+	unknown := Position{-1, -1, -1, -1, -1}
+	if e.StartPos < 0 || e.EndPos < e.StartPos {
+		return unknown, unknown
+	}
+	start, ok := positionAt(input, e.StartPos)
+	if !ok {
+		return unknown, unknown
+	}
+	end, ok = positionAt(input, e.EndPos)
+	if !ok {
+		return unknown, unknown
+	}
+	return start, end
+}
+
+// positionAt converts the rune offset in the input to Position. ok is false if the input is shorter than the offset
+func positionAt(input string, runeOffset int) (pos Position, ok bool) {
+	pos = Position{Line: 1, Column: 1}
+	for pos.Byte < len(input) && pos.Rune != runeOffset {
+		// an invalid byte is decoded as utf8.RuneError of size 1, like the lexer reads it
+		ch, size := utf8.DecodeRuneInString(input[pos.Byte:])
+		pos.Byte += size
+		pos.Rune++
+		pos.UTF16++
+		if ch >= 0x10000 { // encoded as surrogate pair in UTF-16
+			pos.UTF16++
+		}
+		pos.Column++
+		if ch == '\n' {
+			pos.Line++
+			pos.Column = 1
+		}
+	}
+	return pos, pos.Rune == runeOffset
+}
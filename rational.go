@@ -0,0 +1,244 @@
+package calculator
+
+import (
+	"errors"
+	"math/big"
+	"strings"
+)
+
+// ErrNotExact is returned by the exact and arbitrary-precision evaluation modes
+// when the result cannot be calculated exactly or with the requested precision, e.g. "2^0.5" in EvalRat,
+// or custom functions which are calculated with float64
+var ErrNotExact = errors.New("result cannot be calculated exactly")
+
+// maxRatBits limits the size of the exact powers, so that "2^(10^12)" is reported as overflow instead of running out of memory
+const maxRatBits = 1 << 24
+
+// RatEnv holds the exact values of the variables that are used in the expression
+type RatEnv map[string]*big.Rat
+
+// RatCalculatable is a node of the expression tree that can be calculated exactly, see Calculator.EvalRat
+type RatCalculatable interface {
+	CalculateRat(env RatEnv) (*big.Rat, error)
+}
+
+// Rational is the exact result of Calculator.EvalRat, which always has a Rat, even when it fails.
+// The zero value renders as 0 with Fraction, Decimal and String, but the methods of big.Rat need the Rat
+type Rational struct {
+	*big.Rat
+}
+
+// rat returns the number, or 0 for the zero value
+func (r Rational) rat() *big.Rat {
+	if r.Rat == nil {
+		return new(big.Rat)
+	}
+	return r.Rat
+}
+
+// Fraction renders the number as a fraction like "3/10", or as an integer like "3" when the denominator is 1
+func (r Rational) Fraction() string {
+	return r.rat().RatString()
+}
+
+// Decimal renders the number with `digits` digits after the decimal point.
+// The last digit is rounded half away from zero, e.g. "2/3" is "0.67" with 2 digits
+func (r Rational) Decimal(digits int) string {
+	return r.rat().FloatString(digits)
+}
+
+// String renders the number as a fraction like "3/10", including the denominator 1 of the integers, like big.Rat
+func (r Rational) String() string {
+	return r.rat().String()
+}
+
+// EvalRat calculates given mathematical expression exactly, with rational numbers instead of float64,
+// so that "0.1+0.2" is exactly "3/10".
+// "+", "-", "*" and "/" are always exact, "^" is exact only with integer exponents.
+// Non-integer exponents, function calls and custom operators are reported as ErrNotExact
+func (c Calculator) EvalRat(input string) (Rational, error) {
+	return c.EvalRatWith(input, nil)
+}
+
+// EvalRatWith calculates given mathematical expression exactly using the values of the variables in `vars`.
+// Variables with a nil value are unknown, like the ones that are not in `vars`. See #EvalRat
+func (c Calculator) EvalRatWith(input string, vars map[string]*big.Rat) (Rational, error) {
+	// exact results are always finite, so they are not wrapped in StrictNode
+	c.strict = false
+	headNode, err := c.compile(input)
+	if err != nil {
+		return Rational{new(big.Rat)}, err
+	}
+	res, err := calculateRat(headNode, vars)
+	if err != nil {
+		return Rational{new(big.Rat)}, err
+	}
+	return Rational{res}, nil
+}
+
+// calculateRat calculates the node exactly. Nodes that don't support it are reported as ErrNotExact
+func calculateRat(node Calculatable, env RatEnv) (*big.Rat, error) {
+	ratNode, ok := node.(RatCalculatable)
+	if !ok {
+		return nil, EvalError{ErrNotExact, -1, -1}
+	}
+	return ratNode.CalculateRat(env)
+}
+
+// calculateRatOperands calculates the left and the right operands of a binary node exactly
+func calculateRatOperands(left, right Calculatable, env RatEnv) (*big.Rat, *big.Rat, error) {
+	a, err := calculateRat(left, env)
+	if err != nil {
+		return nil, nil, err
+	}
+	b, err := calculateRat(right, env)
+	if err != nil {
+		return nil, nil, err
+	}
+	return a, b, nil
+}
+
+// parseRat converts the valid numeric literal to a rational number.
+// ok is false if the exponent of the literal is too large to be calculated exactly
+func parseRat(literal string) (r *big.Rat, ok bool) {
+	literal = strings.ReplaceAll(literal, "_", "")
+	if n, ok := parseRadixLiteral(literal); ok {
+		return new(big.Rat).SetInt(n), true
+	}
+	return new(big.Rat).SetString(literal)
+}
+
+func (n NumNode) CalculateRat(env RatEnv) (*big.Rat, error) {
+	r, ok := parseRat(n.Literal)
+	if !ok {
+		return nil, EvalError{ErrOverflow, n.StartPos, n.EndPos}
+	}
+	return r, nil
+}
+
+func (n VarNode) CalculateRat(env RatEnv) (*big.Rat, error) {
+	val, ok := env[n.Name]
+	if !ok || val == nil {
+		return nil, EvalError{ErrUnknownVariable, n.StartPos, n.EndPos}
+	}
+	// the result is modified by the parent nodes, so the value of the variable is copied
+	return new(big.Rat).Set(val), nil
+}
+
+func (n AddNode) CalculateRat(env RatEnv) (*big.Rat, error) {
+	a, b, err := calculateRatOperands(n.Left, n.Right, env)
+	if err != nil {
+		return nil, err
+	}
+	return a.Add(a, b), nil
+}
+
+func (n SubNode) CalculateRat(env RatEnv) (*big.Rat, error) {
+	a, b, err := calculateRatOperands(n.Left, n.Right, env)
+	if err != nil {
+		return nil, err
+	}
+	return a.Sub(a, b), nil
+}
+
+func (n NegNode) CalculateRat(env RatEnv) (*big.Rat, error) {
+	a, err := calculateRat(n.Value, env)
+	if err != nil {
+		return nil, err
+	}
+	return a.Neg(a), nil
+}
+
+func (n MulNode) CalculateRat(env RatEnv) (*big.Rat, error) {
+	a, b, err := calculateRatOperands(n.Left, n.Right, env)
+	if err != nil {
+		return nil, err
+	}
+	return a.Mul(a, b), nil
+}
+
+func (n DivNode) CalculateRat(env RatEnv) (*big.Rat, error) {
+	a, b, err := calculateRatOperands(n.Left, n.Right, env)
+	if err != nil {
+		return nil, err
+	}
+	if b.Sign() == 0 {
+		return nil, EvalError{ErrDivisionByZero, n.StartPos, n.EndPos}
+	}
+	return a.Quo(a, b), nil
+}
+
+// CalculateRat raises to the power exactly only if the exponent is an integer,
+// otherwise the result is usually irrational, e.g. "2^0.5", and ErrNotExact is returned
+func (n PowNode) CalculateRat(env RatEnv) (*big.Rat, error) {
+	a, b, err := calculateRatOperands(n.Left, n.Right, env)
+	if err != nil {
+		return nil, err
+	}
+	res, err := powRat(a, b)
+	if err != nil {
+		return nil, EvalError{err, n.StartPos, n.EndPos}
+	}
+	return res, nil
+}
+
+// powRat calculates a^b exactly, if b is an integer. `a` is modified
+func powRat(a, b *big.Rat) (*big.Rat, error) {
+	if !b.IsInt() {
+		return nil, ErrNotExact
+	}
+	if a.Sign() == 0 && b.Sign() < 0 {
+		return nil, ErrDivisionByZero
+	}
+	if b.Sign() == 0 {
+		return a.SetInt64(1), nil
+	}
+	// 0, 1 and -1 stay small with any exponent
+	if a.Sign() == 0 || a.Num().CmpAbs(a.Denom()) == 0 {
+		if a.Sign() < 0 && b.Num().Bit(0) == 0 {
+			return a.Neg(a), nil
+		}
+		return a, nil
+	}
+	exp := new(big.Int).Abs(b.Num())
+	bits := new(big.Int).Mul(exp, big.NewInt(int64(a.Num().BitLen()+a.Denom().BitLen())))
+	if !bits.IsInt64() || bits.Int64() > maxRatBits {
+		return nil, ErrOverflow
+	}
+	num := new(big.Int).Exp(a.Num(), exp, nil)
+	denom := new(big.Int).Exp(a.Denom(), exp, nil)
+	res := new(big.Rat).SetFrac(num, denom)
+	if b.Sign() < 0 {
+		res.Inv(res)
+	}
+	return res, nil
+}
+
+func (n RemNode) CalculateRat(env RatEnv) (*big.Rat, error) {
+	a, b, err := calculateRatOperands(n.Left, n.Right, env)
+	if err != nil {
+		return nil, err
+	}
+	if b.Sign() == 0 {
+		return nil, EvalError{ErrDivisionByZero, n.StartPos, n.EndPos}
+	}
+	return remRat(a, b), nil
+}
+
+// remRat calculates the remainder of a/b exactly, with the sign of `a`
+func remRat(a, b *big.Rat) *big.Rat {
+	// a/b is (a.Num·b.Denom) / (a.Denom·b.Num), and Quo truncates it towards zero
+	q := new(big.Int).Quo(new(big.Int).Mul(a.Num(), b.Denom()), new(big.Int).Mul(a.Denom(), b.Num()))
+	bq := new(big.Rat).Mul(b, new(big.Rat).SetInt(q))
+	return new(big.Rat).Sub(a, bq)
+}
+
+// CalculateRat reports ErrNotExact, because functions are calculated with float64
+func (n FuncNode) CalculateRat(env RatEnv) (*big.Rat, error) {
+	return nil, EvalError{ErrNotExact, n.StartPos, n.EndPos}
+}
+
+// CalculateRat reports ErrNotExact, because custom operators are calculated with float64
+func (n OperatorNode) CalculateRat(env RatEnv) (*big.Rat, error) {
+	return nil, EvalError{ErrNotExact, n.StartPos, n.EndPos}
+}
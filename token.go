@@ -3,54 +3,105 @@ package calculator
 // TOKENS
 const (
 	NUM = iota
+	// Deprecated: the lexer doesn't produce ADD, SUB, MUL, DIV and POW anymore.
+	// All the operators are OP, and the operator is the Value of the Token
 	ADD
+	// Deprecated: see ADD
 	SUB
+	// Deprecated: see ADD
 	MUL
+	// Deprecated: see ADD
 	DIV
+	// Deprecated: see ADD
 	POW
 	L_PAR
 	R_PAR
 
 	SPACE
+	OP
+	IDENT
+	COMMA
+
+	// resolved by the parser: prefix operators from OP, function calls from IDENT
+	UNARY_OP
+	FUNC
 )
 
 type Token struct {
 	Type  int
 	Value string
+	// position of the token in the raw input
+	StartPos int
+	EndPos   int
+	// Err is the reason why the NUM token is malformed
+	Err error
 }
 
 func (t Token) IsNum() bool {
 	return t.Type == NUM && t.Value != ""
 }
 
+func (t Token) IsIdent() bool {
+	return t.Type == IDENT
+}
+func (t Token) IsFunc() bool {
+	return t.Type == FUNC
+}
+func (t Token) IsComma() bool {
+	return t.Type == COMMA
+}
+
 func (t Token) IsOP() bool {
-	return t.Type == ADD || t.Type == SUB || t.Type == MUL || t.Type == DIV || t.Type == POW
+	return t.Type == OP
+}
+func (t Token) IsUnaryOP() bool {
+	return t.Type == UNARY_OP
 }
 func (t Token) IsSpace() bool {
 	return t.Type == SPACE
 }
 
+func (t Token) IsParacentesis() bool {
+	return t.Type == L_PAR || t.Type == R_PAR
+}
+func (t Token) IsLeftParacentesis() bool {
+	return t.Type == L_PAR
+}
+func (t Token) IsRightParacentesis() bool {
+	return t.Type == R_PAR
+}
+
+// IsAddOP checks if the token is the "+" operator.
+//
+// Deprecated: use IsOP and compare the Value with "+"
 func (t Token) IsAddOP() bool {
-	return t.Type == ADD
+	return t.IsOP() && t.Value == "+"
 }
+
+// IsSubOP checks if the token is the "-" operator.
+//
+// Deprecated: use IsOP and compare the Value with "-"
 func (t Token) IsSubOP() bool {
-	return t.Type == SUB
+	return t.IsOP() && t.Value == "-"
 }
+
+// IsMulOP checks if the token is the "*" operator.
+//
+// Deprecated: use IsOP and compare the Value with "*"
 func (t Token) IsMulOP() bool {
-	return t.Type == MUL
+	return t.IsOP() && t.Value == "*"
 }
+
+// IsDivOP checks if the token is the "/" operator.
+//
+// Deprecated: use IsOP and compare the Value with "/"
 func (t Token) IsDivOP() bool {
-	return t.Type == DIV
+	return t.IsOP() && t.Value == "/"
 }
+
+// IsPowOP checks if the token is the "^" operator.
+//
+// Deprecated: use IsOP and compare the Value with "^"
 func (t Token) IsPowOP() bool {
-	return t.Type == POW
-}
-func (t Token) IsParacentesis() bool {
-	return t.Type == L_PAR || t.Type == R_PAR
-}
-func (t Token) IsLeftParacentesis() bool {
-	return t.Type == L_PAR
-}
-func (t Token) IsRightParacentesis() bool {
-	return t.Type == R_PAR
+	return t.IsOP() && t.Value == "^"
 }
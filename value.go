@@ -0,0 +1,425 @@
+package calculator
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ErrTypeMismatch is returned by CompileTyped and EvalValue when an operator has no Overload
+// for the types of its operands, e.g. "-ok" where ok is a Bool, or a function is called with a non-numeric argument.
+// It is also returned when Overload.Fn returns a Value that is not of its Result type
+var ErrTypeMismatch = errors.New("operands have wrong types")
+
+// Type is the type of a Value
+type Type int
+
+const (
+	FloatType Type = iota
+	IntType
+	BoolType
+)
+
+func (t Type) String() string {
+	switch t {
+	case FloatType:
+		return "float"
+	case IntType:
+		return "int"
+	case BoolType:
+		return "bool"
+	}
+	return "Type(" + strconv.Itoa(int(t)) + ")"
+}
+
+// Value is the result of a node in the typed evaluation mode, see Calculator.EvalValue.
+// It is one of Float, Int or Bool
+type Value interface {
+	Type() Type
+}
+
+// Float is a float64 Value
+type Float float64
+
+// Int is an int64 Value
+type Int int64
+
+// Bool is a boolean Value
+type Bool bool
+
+func (Float) Type() Type { return FloatType }
+func (Int) Type() Type   { return IntType }
+func (Bool) Type() Type  { return BoolType }
+
+// Overload is the implementation of an operator for the given types of the operands.
+// Fn is called with the Values of the Operands types, and must return a Value of the Result type.
+// Errors returned by Fn are reported as EvalError with the position of the operator
+// example:
+// calculator.Overload{[]calculator.Type{calculator.IntType, calculator.IntType}, calculator.BoolType, intEquals}
+type Overload struct {
+	Operands []Type
+	Result   Type
+	Fn       func(args ...Value) (Value, error)
+}
+
+// matches checks if the Overload can be called with the operands of given types.
+// Int operands can be passed as Float, unless `exact` is set
+func (o Overload) matches(types []Type, exact bool) bool {
+	if len(o.Operands) != len(types) {
+		return false
+	}
+	for i, t := range types {
+		if t != o.Operands[i] && (exact || t != IntType || o.Operands[i] != FloatType) {
+			return false
+		}
+	}
+	return true
+}
+
+// call converts the Int arguments to Float where the Overload needs it, and calls it
+func (o Overload) call(args []Value) (Value, error) {
+	converted := make([]Value, len(args))
+	for i, arg := range args {
+		if n, ok := arg.(Int); ok && o.Operands[i] == FloatType {
+			converted[i] = Float(n)
+		} else {
+			converted[i] = arg
+		}
+	}
+	return o.Fn(converted...)
+}
+
+// resolveOverload finds the Overload for the operands of given types.
+// Overloads that match the types exactly are preferred over the ones that need Int to Float conversion
+func resolveOverload(overloads []Overload, types []Type) (Overload, bool) {
+	for _, exact := range []bool{true, false} {
+		for _, o := range overloads {
+			if o.matches(types, exact) {
+				return o, true
+			}
+		}
+	}
+	return Overload{}, false
+}
+
+// floatOverload creates the Overload that calculates the operator with float64, from the Fn of the OperatorSpec
+func floatOverload(arity int, fn func(args ...float64) float64) Overload {
+	operands := make([]Type, arity)
+	for i := range operands {
+		operands[i] = FloatType
+	}
+	return Overload{operands, FloatType, func(args ...Value) (Value, error) {
+		floats := make([]float64, len(args))
+		for i, arg := range args {
+			floats[i] = float64(arg.(Float))
+		}
+		return Float(fn(floats...)), nil
+	}}
+}
+
+// binaryIntOverload creates the Overload of a binary operator for Int operands
+func binaryIntOverload(fn func(a, b int64) (int64, error)) Overload {
+	return Overload{[]Type{IntType, IntType}, IntType, func(args ...Value) (Value, error) {
+		res, err := fn(int64(args[0].(Int)), int64(args[1].(Int)))
+		return Int(res), err
+	}}
+}
+
+// unaryIntOverload creates the Overload of a prefix operator for an Int operand
+func unaryIntOverload(fn func(a int64) (int64, error)) Overload {
+	return Overload{[]Type{IntType}, IntType, func(args ...Value) (Value, error) {
+		res, err := fn(int64(args[0].(Int)))
+		return Int(res), err
+	}}
+}
+
+// addInt adds the integers, and reports ErrOverflow instead of wrapping around
+func addInt(a, b int64) (int64, error) {
+	res := a + b
+	if (b > 0 && res < a) || (b < 0 && res > a) {
+		return 0, ErrOverflow
+	}
+	return res, nil
+}
+
+// subInt subtracts the integers, and reports ErrOverflow instead of wrapping around
+func subInt(a, b int64) (int64, error) {
+	res := a - b
+	if (b > 0 && res > a) || (b < 0 && res < a) {
+		return 0, ErrOverflow
+	}
+	return res, nil
+}
+
+// mulInt multiplies the integers, and reports ErrOverflow instead of wrapping around
+func mulInt(a, b int64) (int64, error) {
+	if a == 0 || b == 0 {
+		return 0, nil
+	}
+	res := a * b
+	// -1 * MinInt64 wraps around to MinInt64, and the division doesn't catch it
+	if res/b != a || (a == -1 && b == math.MinInt64) || (b == -1 && a == math.MinInt64) {
+		return 0, ErrOverflow
+	}
+	return res, nil
+}
+
+// negInt negates the integer. -MinInt64 doesn't fit in int64, so it is reported as ErrOverflow
+func negInt(a int64) (int64, error) {
+	if a == math.MinInt64 {
+		return 0, ErrOverflow
+	}
+	return -a, nil
+}
+
+// parseInt converts the numeric literal to int64.
+// ok is false if the literal has a fraction or an exponent, or if it doesn't fit in int64
+func parseInt(literal string) (n int64, ok bool) {
+	literal = strings.ReplaceAll(literal, "_", "")
+	if n, ok := parseRadixLiteral(literal); ok {
+		return n.Int64(), n.IsInt64()
+	}
+	n, err := strconv.ParseInt(literal, 10, 64)
+	return n, err == nil
+}
+
+// TypeEnv holds the types of the variables that are used in the typed expression, see Calculator.CompileTyped
+type TypeEnv map[string]Type
+
+// ValueEnv holds the values of the variables that are used in the typed expression, see Calculator.EvalValue
+type ValueEnv map[string]Value
+
+// ValueCalculatable is a node of the expression tree that can be calculated in the typed evaluation mode.
+// CheckType finds the type of the result before it is calculated, and reports the operators and
+// the function calls that cannot be calculated with the types of their operands as ErrTypeMismatch
+type ValueCalculatable interface {
+	CheckType(types TypeEnv) (Type, error)
+	CalculateValue(env ValueEnv) (Value, error)
+}
+
+// EvalValue calculates given mathematical expression in the typed evaluation mode, with typed Values instead of float64.
+// It is an additional evaluation mode like EvalRat; Eval and the other modes keep calculating the expression tree with their own numbers.
+// Integer literals like "2" or "0xFF" are Int, other numbers are Float.
+// The built-in "+", "-" and "*" calculate Int operands as Int, and report int64 overflow as ErrOverflow.
+// "/", "^" and the functions convert Int to Float, so "7/2" is Float 3.5, same as in Eval.
+// Custom operators are calculated by their Overloads, or by their Fn with Float operands.
+// Types are checked before the expression is calculated, see #CompileTyped
+func (c Calculator) EvalValue(input string) (Value, error) {
+	return c.EvalValueWith(input, nil)
+}
+
+// EvalValueWith calculates given mathematical expression with typed Values using the values of the variables in `vars`.
+// Variables with a nil Value are unknown, like the ones that are not in `vars`. See #EvalValue
+func (c Calculator) EvalValueWith(input string, vars map[string]Value) (Value, error) {
+	types := make(TypeEnv, len(vars))
+	for name, val := range vars {
+		if val != nil {
+			types[name] = val.Type()
+		}
+	}
+	expr, err := c.CompileTyped(input, types)
+	if err != nil {
+		return nil, err
+	}
+	return calculateValue(expr.headNode, vars)
+}
+
+// CompileTyped validates the expression, builds the typed expression tree and checks the types of
+// all the operators and function calls with the types of the variables in `types`.
+// Unknown variables are reported as ErrUnknownVariable, and wrong types as ErrTypeMismatch,
+// with the position of the variable, the operator or the function call
+func (c Calculator) CompileTyped(input string, types map[string]Type) (*TypedExpression, error) {
+	c.typed = true
+	headNode, err := c.compile(input)
+	if err != nil {
+		return nil, err
+	}
+	typ, err := checkType(headNode, types)
+	if err != nil {
+		return nil, err
+	}
+	copied := make(TypeEnv, len(types))
+	for name, t := range types {
+		copied[name] = t
+	}
+	return &TypedExpression{input, headNode, copied, typ}, nil
+}
+
+// checkType finds the type of the node. Nodes that aren't typed are reported as ErrTypeMismatch
+func checkType(node Calculatable, types TypeEnv) (Type, error) {
+	valueNode, ok := node.(ValueCalculatable)
+	if !ok {
+		return 0, EvalError{ErrTypeMismatch, -1, -1}
+	}
+	return valueNode.CheckType(types)
+}
+
+// calculateValue calculates the node with typed Values. Nodes that aren't typed are reported as ErrTypeMismatch
+func calculateValue(node Calculatable, env ValueEnv) (Value, error) {
+	valueNode, ok := node.(ValueCalculatable)
+	if !ok {
+		return nil, EvalError{ErrTypeMismatch, -1, -1}
+	}
+	return valueNode.CalculateValue(env)
+}
+
+func (n NumNode) CheckType(types TypeEnv) (Type, error) {
+	if _, ok := parseInt(n.Literal); ok {
+		return IntType, nil
+	}
+	return FloatType, nil
+}
+
+func (n NumNode) CalculateValue(env ValueEnv) (Value, error) {
+	if i, ok := parseInt(n.Literal); ok {
+		return Int(i), nil
+	}
+	return Float(n.Value), nil
+}
+
+func (n VarNode) CheckType(types TypeEnv) (Type, error) {
+	t, ok := types[n.Name]
+	if !ok {
+		return 0, EvalError{ErrUnknownVariable, n.StartPos, n.EndPos}
+	}
+	return t, nil
+}
+
+func (n StrictNode) CheckType(types TypeEnv) (Type, error) {
+	return checkType(n.Node, types)
+}
+
+// CalculateValue reports the non-finite Float results as EvalError, like Calculate. Other Values are returned as they are
+func (n StrictNode) CalculateValue(env ValueEnv) (Value, error) {
+	res, err := calculateValue(n.Node, env)
+	if err != nil {
+		return nil, err
+	}
+	f, ok := res.(Float)
+	if !ok {
+		return res, nil
+	}
+	if math.IsNaN(float64(f)) {
+		return nil, EvalError{ErrNotANumber, n.StartPos, n.EndPos}
+	}
+	if math.IsInf(float64(f), 0) {
+		return nil, EvalError{n.infiniteError(floatEnv(env)), n.StartPos, n.EndPos}
+	}
+	return res, nil
+}
+
+// floatEnv converts the Int and Float variables to float64, so the operands can be calculated again by infiniteError
+func floatEnv(env ValueEnv) Env {
+	floats := make(Env, len(env))
+	for name, val := range env {
+		switch v := val.(type) {
+		case Float:
+			floats[name] = float64(v)
+		case Int:
+			floats[name] = float64(v)
+		}
+	}
+	return floats
+}
+
+func (n VarNode) CalculateValue(env ValueEnv) (Value, error) {
+	val, ok := env[n.Name]
+	if !ok || val == nil {
+		return nil, EvalError{ErrUnknownVariable, n.StartPos, n.EndPos}
+	}
+	return val, nil
+}
+
+// CheckType accepts only Int and Float arguments, because functions are calculated with float64
+func (n FuncNode) CheckType(types TypeEnv) (Type, error) {
+	for _, arg := range n.Args {
+		t, err := checkType(arg, types)
+		if err != nil {
+			return 0, err
+		}
+		if t != FloatType && t != IntType {
+			return 0, EvalError{ErrTypeMismatch, n.StartPos, n.EndPos}
+		}
+	}
+	return FloatType, nil
+}
+
+func (n FuncNode) CalculateValue(env ValueEnv) (Value, error) {
+	args := make([]float64, len(n.Args))
+	for i, arg := range n.Args {
+		val, err := calculateValue(arg, env)
+		if err != nil {
+			return nil, err
+		}
+		switch v := val.(type) {
+		case Float:
+			args[i] = float64(v)
+		case Int:
+			args[i] = float64(v)
+		default:
+			return nil, EvalError{ErrTypeMismatch, n.StartPos, n.EndPos}
+		}
+	}
+	return Float(n.Fn(args...)), nil
+}
+
+// OverloadNode calculates an operator in the typed expressions, with the Overload that matches the types of the operands.
+// Fn is used when it is calculated with float64.
+// StartPos and EndPos are the position of the operator
+type OverloadNode struct {
+	Symbol    string
+	Fn        func(args ...float64) float64
+	Overloads []Overload
+	Operands  []Calculatable
+	StartPos  int
+	EndPos    int
+	// floatNode is the node that Eval builds for the operator, used by StrictNode to find out why a Float result is infinite
+	floatNode Calculatable
+}
+
+func (n OverloadNode) Calculate(env Env) (float64, error) {
+	return OperatorNode{n.Symbol, n.Fn, n.Operands, n.StartPos, n.EndPos}.Calculate(env)
+}
+
+func (n OverloadNode) CheckType(types TypeEnv) (Type, error) {
+	operandTypes := make([]Type, len(n.Operands))
+	for i, operand := range n.Operands {
+		t, err := checkType(operand, types)
+		if err != nil {
+			return 0, err
+		}
+		operandTypes[i] = t
+	}
+	o, ok := resolveOverload(n.Overloads, operandTypes)
+	if !ok {
+		return 0, EvalError{ErrTypeMismatch, n.StartPos, n.EndPos}
+	}
+	return o.Result, nil
+}
+
+func (n OverloadNode) CalculateValue(env ValueEnv) (Value, error) {
+	args := make([]Value, len(n.Operands))
+	operandTypes := make([]Type, len(n.Operands))
+	for i, operand := range n.Operands {
+		val, err := calculateValue(operand, env)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = val
+		operandTypes[i] = val.Type()
+	}
+	o, ok := resolveOverload(n.Overloads, operandTypes)
+	if !ok {
+		return nil, EvalError{ErrTypeMismatch, n.StartPos, n.EndPos}
+	}
+	res, err := o.call(args)
+	if err != nil {
+		return nil, EvalError{err, n.StartPos, n.EndPos}
+	}
+	// the parent nodes are checked with the Result type of the Overload, so a Value of another type would break them
+	if res == nil || res.Type() != o.Result {
+		return nil, EvalError{ErrTypeMismatch, n.StartPos, n.EndPos}
+	}
+	return res, nil
+}